@@ -3,7 +3,11 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"monkey/token"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -96,6 +100,27 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// DebuggerStatement marks a point where execution pauses for a debugger.
+type DebuggerStatement struct {
+	Token token.Token // the token.DEBUGGER token
+}
+
+func (ds *DebuggerStatement) statementNode()       {}
+func (ds *DebuggerStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DebuggerStatement) String() string       { return ds.TokenLiteral() + ";" }
+
+// ImportStatement loads another Monkey file, as in `import "util.monkey";`.
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT token
+	Path  string
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " " + strconv.Quote(is.Path) + ";"
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -119,6 +144,25 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// BigIntegerLiteral is an integer literal too large for an int64.
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode()      {}
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) String() string       { return bl.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -193,6 +237,83 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// WhileExpression runs Body for as long as Condition is truthy. As an
+// expression it evaluates to null.
+type WhileExpression struct {
+	Token     token.Token // the token.WHILE token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode()      {}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(we.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
+// ForeachExpression runs Body once for every element of an array or every
+// pair of a hash. Value is bound to the element or hash value, and Key, when
+// present, to the array index or hash key. As an expression it evaluates to
+// null.
+type ForeachExpression struct {
+	Token    token.Token // the token.FOREACH token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForeachExpression) expressionNode()      {}
+func (fe *ForeachExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForeachExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("foreach(")
+	if fe.Key != nil {
+		out.WriteString(fe.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fe.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
+// TryExpression runs Body and, if it fails with a runtime error, binds the
+// error message to Parameter and runs Handler instead. It evaluates to the
+// value of whichever block ran last.
+type TryExpression struct {
+	Token     token.Token // the token.TRY token
+	Body      *BlockStatement
+	Parameter *Identifier
+	Handler   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	out.WriteString(" catch(")
+	out.WriteString(te.Parameter.String())
+	out.WriteString(") ")
+	out.WriteString(te.Handler.String())
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -263,6 +384,74 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// HasKeywordArguments reports whether any argument of the call is passed by
+// parameter name.
+func (ce *CallExpression) HasKeywordArguments() bool {
+	for _, argument := range ce.Arguments {
+		if _, ok := argument.(*KeywordArgument); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PositionalArguments matches the arguments of the call to parameters, moving
+// each keyword argument to the position of the parameter it names. Positional
+// arguments have to precede keyword arguments, which the parser enforces.
+func (ce *CallExpression) PositionalArguments(parameters []string) ([]Expression, error) {
+	if !ce.HasKeywordArguments() {
+		return ce.Arguments, nil
+	}
+
+	arguments := make([]Expression, len(parameters))
+
+	for index, argument := range ce.Arguments {
+		keyword, ok := argument.(*KeywordArgument)
+		if !ok {
+			if index >= len(parameters) {
+				return nil, fmt.Errorf("too many arguments: want=%d, got=%d",
+					len(parameters), len(ce.Arguments))
+			}
+
+			arguments[index] = argument
+			continue
+		}
+
+		position := slices.Index(parameters, keyword.Name.Value)
+		if position < 0 {
+			return nil, fmt.Errorf("unexpected keyword argument %s", keyword.Name.Value)
+		}
+
+		if arguments[position] != nil {
+			return nil, fmt.Errorf("multiple values for argument %s", keyword.Name.Value)
+		}
+
+		arguments[position] = keyword.Value
+	}
+
+	for index, argument := range arguments {
+		if argument == nil {
+			return nil, fmt.Errorf("missing argument %s", parameters[index])
+		}
+	}
+
+	return arguments, nil
+}
+
+// KeywordArgument is a call argument passed by parameter name, as in `f(x: 1)`.
+type KeywordArgument struct {
+	Token token.Token // the token.IDENT token of the name
+	Name  *Identifier
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode()      {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Token.Literal }
+func (ka *KeywordArgument) String() string {
+	return ka.Name.String() + ": " + ka.Value.String()
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -317,6 +506,8 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Keys lists the keys of Pairs in source order.
+	Keys []Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -325,8 +516,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, valu := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+valu.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -335,3 +526,21 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// OrderedKeys returns the keys of the literal in source order. Literals built
+// without Keys fall back to ordering the keys by their string form.
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := []Expression{}
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
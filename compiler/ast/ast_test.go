@@ -26,3 +26,74 @@ func TestString(tester *testing.T) {
 		tester.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestWalk(tester *testing.T) {
+	integer := func(value int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT}, Value: value}
+	}
+
+	// let result = fn(x) { if (x > 1) { [2, 3] } }(4);
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "result"}, Value: "result"},
+				Value: &CallExpression{
+					Function: &FunctionLiteral{
+						Parameters: []*Identifier{{Value: "x"}},
+						Body: &BlockStatement{
+							Statements: []Statement{
+								&ExpressionStatement{
+									Expression: &IfExpression{
+										Condition: &InfixExpression{
+											Left:     &Identifier{Value: "x"},
+											Operator: ">",
+											Right:    integer(1),
+										},
+										Consequence: &BlockStatement{
+											Statements: []Statement{
+												&ExpressionStatement{
+													Expression: &ArrayLiteral{
+														Elements: []Expression{integer(2), integer(3)},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					Arguments: []Expression{integer(4)},
+				},
+			},
+		},
+	}
+
+	count := 0
+	Walk(program, func(node Node) bool {
+		if _, ok := node.(*IntegerLiteral); ok {
+			count++
+		}
+		return true
+	})
+
+	if count != 4 {
+		tester.Errorf("wrong number of integer literals. want=%d, got=%d", 4, count)
+	}
+
+	count = 0
+	Walk(program, func(node Node) bool {
+		if _, ok := node.(*FunctionLiteral); ok {
+			return false
+		}
+		if _, ok := node.(*IntegerLiteral); ok {
+			count++
+		}
+		return true
+	})
+
+	if count != 1 {
+		tester.Errorf("wrong number of integer literals outside functions. want=%d, got=%d", 1, count)
+	}
+}
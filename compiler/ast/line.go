@@ -0,0 +1,58 @@
+package ast
+
+// Line returns the source line of the token that starts node, or 0 for nodes
+// without a position such as a whole program.
+func Line(node Node) int {
+	switch node := node.(type) {
+	case *LetStatement:
+		return node.Token.Line
+	case *Identifier:
+		return node.Token.Line
+	case *ReturnStatement:
+		return node.Token.Line
+	case *DebuggerStatement:
+		return node.Token.Line
+	case *ImportStatement:
+		return node.Token.Line
+	case *ExpressionStatement:
+		return node.Token.Line
+	case *IntegerLiteral:
+		return node.Token.Line
+	case *BigIntegerLiteral:
+		return node.Token.Line
+	case *FloatLiteral:
+		return node.Token.Line
+	case *PrefixExpression:
+		return node.Token.Line
+	case *InfixExpression:
+		return node.Token.Line
+	case *Boolean:
+		return node.Token.Line
+	case *IfExpression:
+		return node.Token.Line
+	case *WhileExpression:
+		return node.Token.Line
+	case *ForeachExpression:
+		return node.Token.Line
+	case *TryExpression:
+		return node.Token.Line
+	case *BlockStatement:
+		return node.Token.Line
+	case *FunctionLiteral:
+		return node.Token.Line
+	case *CallExpression:
+		return node.Token.Line
+	case *KeywordArgument:
+		return node.Token.Line
+	case *StringLiteral:
+		return node.Token.Line
+	case *ArrayLiteral:
+		return node.Token.Line
+	case *IndexExpression:
+		return node.Token.Line
+	case *HashLiteral:
+		return node.Token.Line
+	}
+
+	return 0
+}
@@ -0,0 +1,104 @@
+package ast
+
+// Walk traverses the tree rooted at node in pre-order, calling fn for every
+// node it visits. When fn returns false the children of that node are skipped.
+func Walk(node Node, fn func(Node) bool) {
+	if isNilNode(node) || !fn(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			Walk(statement, fn)
+		}
+
+	case *LetStatement:
+		Walk(node.Name, fn)
+		Walk(node.Value, fn)
+
+	case *ReturnStatement:
+		Walk(node.ReturnValue, fn)
+
+	case *ExpressionStatement:
+		Walk(node.Expression, fn)
+
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			Walk(statement, fn)
+		}
+
+	case *PrefixExpression:
+		Walk(node.Right, fn)
+
+	case *InfixExpression:
+		Walk(node.Left, fn)
+		Walk(node.Right, fn)
+
+	case *IfExpression:
+		Walk(node.Condition, fn)
+		Walk(node.Consequence, fn)
+		Walk(node.Alternative, fn)
+
+	case *WhileExpression:
+		Walk(node.Condition, fn)
+		Walk(node.Body, fn)
+
+	case *ForeachExpression:
+		Walk(node.Key, fn)
+		Walk(node.Value, fn)
+		Walk(node.Iterable, fn)
+		Walk(node.Body, fn)
+
+	case *TryExpression:
+		Walk(node.Body, fn)
+		Walk(node.Parameter, fn)
+		Walk(node.Handler, fn)
+
+	case *FunctionLiteral:
+		for _, parameter := range node.Parameters {
+			Walk(parameter, fn)
+		}
+		Walk(node.Body, fn)
+
+	case *CallExpression:
+		Walk(node.Function, fn)
+		for _, argument := range node.Arguments {
+			Walk(argument, fn)
+		}
+
+	case *KeywordArgument:
+		Walk(node.Name, fn)
+		Walk(node.Value, fn)
+
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			Walk(element, fn)
+		}
+
+	case *IndexExpression:
+		Walk(node.Left, fn)
+		Walk(node.Index, fn)
+
+	case *HashLiteral:
+		for _, key := range node.OrderedKeys() {
+			Walk(key, fn)
+			Walk(node.Pairs[key], fn)
+		}
+	}
+}
+
+// isNilNode reports whether node is nil or an interface wrapping a nil pointer,
+// which the parser leaves behind for optional children such as a missing else.
+func isNilNode(node Node) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *BlockStatement:
+		return node == nil
+	case *Identifier:
+		return node == nil
+	}
+
+	return false
+}
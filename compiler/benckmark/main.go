@@ -9,10 +9,12 @@ import (
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"os"
 	"time"
 )
 
 var engine = flag.String("engine", "vm", "use 'vm' or 'eval'")
+var trace = flag.Bool("trace", false, "print every instruction the vm executes to stderr")
 
 var input = `
 let fibonacci = fn(x) {
@@ -48,6 +50,9 @@ func main() {
 		}
 
 		machine := vm.New(compiler.Bytecode())
+		if *trace {
+			machine.SetTrace(os.Stderr)
+		}
 
 		start := time.Now()
 
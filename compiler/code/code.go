@@ -53,6 +53,7 @@ type Opcode byte
 
 const (
 	OpConstant Opcode = iota
+	OpSmallInt
 	OpNull
 	OpArray
 	OpHash
@@ -64,6 +65,7 @@ const (
 	OpSub
 	OpMul
 	OpDiv
+	OpMod
 	OpBang
 	OpMinus
 
@@ -76,6 +78,7 @@ const (
 
 	OpJumpNotTrue
 	OpJump
+	OpLoop
 
 	OpCall
 	OpReturnValue
@@ -89,6 +92,13 @@ const (
 	OpGetFree
 
 	OpPop
+
+	OpBreak
+
+	OpSetupCatch
+	OpPopCatch
+
+	OpIterKeys
 )
 
 type Definition struct {
@@ -98,6 +108,7 @@ type Definition struct {
 
 var definitions = map[Opcode]*Definition{
 	OpConstant:       {"OpConstant", []int{2}},
+	OpSmallInt:       {"OpSmallInt", []int{1}},
 	OpNull:           {"OpNull", []int{}},
 	OpArray:          {"OpArray", []int{2}},
 	OpHash:           {"OpHash", []int{2}},
@@ -109,6 +120,7 @@ var definitions = map[Opcode]*Definition{
 	OpSub:   {"OpSub", []int{}},
 	OpMul:   {"OpMul", []int{}},
 	OpDiv:   {"OpDiv", []int{}},
+	OpMod:   {"OpMod", []int{}},
 	OpBang:  {"OpBang", []int{}},
 	OpMinus: {"OpMinus", []int{}},
 
@@ -121,6 +133,7 @@ var definitions = map[Opcode]*Definition{
 
 	OpJumpNotTrue: {"OpJumpNotTrue", []int{2}},
 	OpJump:        {"OpJump", []int{2}},
+	OpLoop:        {"OpLoop", []int{2}},
 
 	OpCall:        {"OpCall", []int{1}},
 	OpReturnValue: {"OpReturnValue", []int{}},
@@ -134,6 +147,13 @@ var definitions = map[Opcode]*Definition{
 	OpGetFree:    {"OpGetFree", []int{1}},
 
 	OpPop: {"OpPop", []int{}},
+
+	OpBreak: {"OpBreak", []int{}},
+
+	OpSetupCatch: {"OpSetupCatch", []int{2}},
+	OpPopCatch:   {"OpPopCatch", []int{}},
+
+	OpIterKeys: {"OpIterKeys", []int{}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -174,6 +194,22 @@ func Make(op Opcode, operands ...int) []byte {
 	return instruction
 }
 
+// MakeChecked is like Make but reports undefined opcodes and operand counts
+// that don't match the opcode's definition instead of producing bad bytes.
+func MakeChecked(op Opcode, operands ...int) ([]byte, error) {
+	definition, error := Lookup(byte(op))
+	if error != nil {
+		return nil, error
+	}
+
+	if len(operands) != len(definition.OperandWidths) {
+		return nil, fmt.Errorf("wrong number of operands for %s: want=%d, got=%d",
+			definition.Name, len(definition.OperandWidths), len(operands))
+	}
+
+	return Make(op, operands...), nil
+}
+
 func ReadOperands(definition *Definition, instruction Instructions) ([]int, int) {
 	operands := make([]int, len(definition.OperandWidths))
 	offset := 0
@@ -12,6 +12,8 @@ func TestMake(tester *testing.T) {
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
 		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpSmallInt, []int{200}, []byte{byte(OpSmallInt), 200}},
+		{OpLoop, []int{258}, []byte{byte(OpLoop), 1, 2}},
 	}
 
 	for _, testcase := range tests {
@@ -31,6 +33,41 @@ func TestMake(tester *testing.T) {
 	}
 }
 
+func TestMakeChecked(tester *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected string
+	}{
+		{OpConstant, []int{}, "wrong number of operands for OpConstant: want=1, got=0"},
+		{OpAdd, []int{1}, "wrong number of operands for OpAdd: want=0, got=1"},
+		{OpClosure, []int{1}, "wrong number of operands for OpClosure: want=2, got=1"},
+		{Opcode(255), []int{}, "opcode 255 undefined"},
+	}
+
+	for _, testcase := range tests {
+		instruction, error := MakeChecked(testcase.op, testcase.operands...)
+		if error == nil {
+			tester.Fatalf("expected error for %d %v, got instruction %v",
+				testcase.op, testcase.operands, instruction)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong error. want=%q, got=%q", testcase.expected, error)
+		}
+	}
+
+	instruction, error := MakeChecked(OpClosure, 65534, 255)
+	if error != nil {
+		tester.Fatalf("unexpected error: %s", error)
+	}
+
+	expected := Make(OpClosure, 65534, 255)
+	if string(instruction) != string(expected) {
+		tester.Errorf("wrong instruction. want=%v, got=%v", expected, instruction)
+	}
+}
+
 func TestInstructionsString(tester *testing.T) {
 	instructions := []Instructions{
 		Make(OpAdd),
@@ -38,6 +75,8 @@ func TestInstructionsString(tester *testing.T) {
 		Make(OpConstant, 2),
 		Make(OpConstant, 65535),
 		Make(OpClosure, 65535, 255),
+		Make(OpSmallInt, 7),
+		Make(OpLoop, 0),
 	}
 
 	expected := `0000 OpAdd
@@ -45,6 +84,8 @@ func TestInstructionsString(tester *testing.T) {
 0003 OpConstant 2
 0006 OpConstant 65535
 0009 OpClosure 65535 255
+0013 OpSmallInt 7
+0015 OpLoop 0
 `
 
 	concatenated := Instructions{}
@@ -67,6 +108,8 @@ func TestReadOperands(tester *testing.T) {
 		{OpConstant, []int{65535}, 2},
 		{OpGetLocal, []int{255}, 1},
 		{OpClosure, []int{65535, 255}, 3},
+		{OpSmallInt, []int{255}, 1},
+		{OpLoop, []int{65535}, 2},
 	}
 
 	for _, testcase := range tests {
@@ -2,24 +2,73 @@ package compiler
 
 import (
 	"fmt"
+	"maps"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/lexer"
 	"monkey/object"
-	"sort"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 )
 
+// MaxSmallInt is the largest integer literal that is encoded directly in an
+// OpSmallInt operand instead of being added to the constant pool.
+const MaxSmallInt = 255
+
 type Compiler struct {
 	constants []object.Object
+	// integerConstants maps integer values to their index in constants so
+	// equal integers share a single pool entry.
+	integerConstants map[int64]int
+	// stringConstants does the same for strings, so that every occurrence of
+	// a literal is the same *object.String.
+	stringConstants map[string]int
 
 	symbolTable *SymbolTable
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	folding bool
+
+	// withoutBuiltins keeps the builtins out of the symbol table.
+	withoutBuiltins bool
+
+	// lineInfo records the source line of every emitted instruction, and line
+	// is the line of the node being compiled.
+	lineInfo bool
+	line     int
+
+	// importDir is the directory import statements load files from.
+	importDir string
+	// imports maps each imported file to whether it is still being compiled,
+	// which is how import cycles are detected.
+	imports     map[string]bool
+	importChain []string
+
+	// foreachDepth counts the foreach loops being compiled, so that nested
+	// loops get their own hidden bookkeeping variables.
+	foreachDepth int
+	// keywordCallDepth does the same for calls whose keyword arguments are
+	// reordered.
+	keywordCallDepth int
+	// blockDepth counts the blocks being compiled, which imports must not be
+	// inside.
+	blockDepth int
+
+	// warnings collects problems that do not stop compilation.
+	warnings []string
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	// LineInfo holds the source line of the instruction at each offset of
+	// Instructions. It is only filled in when line info is enabled.
+	LineInfo []int
 }
 
 type EmittedInstruction struct {
@@ -31,27 +80,49 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	lines               []int
+}
+
+// Option configures a Compiler created by New.
+type Option func(*Compiler)
+
+// WithoutBuiltins leaves the builtins out of the symbol table, for embedders
+// that want a sandbox without puts, file IO or any other builtin. OpGetBuiltin
+// is then never emitted and a builtin name is an undefined variable.
+func WithoutBuiltins() Option {
+	return func(c *Compiler) {
+		c.withoutBuiltins = true
+	}
 }
 
-func New() *Compiler {
+func New(options ...Option) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
 
-	symbolTable := NewSymbolTable()
+	compiler := &Compiler{
+		constants:        []object.Object{},
+		integerConstants: make(map[int64]int),
+		stringConstants:  make(map[string]int),
+		symbolTable:      NewSymbolTable(),
+		scopes:           []CompilationScope{mainScope},
+		scopeIndex:       0,
+		imports:          make(map[string]bool),
+	}
 
-	for index, value := range object.Builtins {
-		symbolTable.DefineBuiltin(index, value.Name)
+	for _, option := range options {
+		option(compiler)
 	}
 
-	return &Compiler{
-		constants:   []object.Object{},
-		symbolTable: symbolTable,
-		scopes:      []CompilationScope{mainScope},
-		scopeIndex:  0,
+	if !compiler.withoutBuiltins {
+		for index, value := range object.Builtins {
+			compiler.symbolTable.DefineBuiltin(index, value.Name)
+		}
 	}
+
+	return compiler
 }
 
 func NewWithState(st *SymbolTable, constants []object.Object) *Compiler {
@@ -59,13 +130,69 @@ func NewWithState(st *SymbolTable, constants []object.Object) *Compiler {
 	compiler.symbolTable = st
 	compiler.constants = constants
 
+	for index, constant := range constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			if _, seen := compiler.integerConstants[constant.Value]; !seen {
+				compiler.integerConstants[constant.Value] = index
+			}
+		case *object.String:
+			if _, seen := compiler.stringConstants[constant.Value]; !seen {
+				compiler.stringConstants[constant.Value] = index
+			}
+		}
+	}
+
 	return compiler
 }
 
+// SetFolding enables or disables constant folding. With folding enabled,
+// integer arithmetic whose operands are all literals, such as `60 * 60`, is
+// evaluated at compile time and emitted as a single integer, and an `if` whose
+// condition is a boolean literal compiles to just the branch it takes.
+func (c *Compiler) SetFolding(enabled bool) {
+	c.folding = enabled
+}
+
+// SetLineInfo enables or disables recording the source line of each emitted
+// instruction in Bytecode.LineInfo and CompiledFunction.LineInfo, which lets
+// the VM report the line of a runtime error.
+func (c *Compiler) SetLineInfo(enabled bool) {
+	c.lineInfo = enabled
+}
+
+// SetImportDir sets the directory that import statements load files from. It
+// defaults to the working directory.
+func (c *Compiler) SetImportDir(dir string) {
+	c.importDir = dir
+}
+
+// Warnings returns the warnings collected so far, such as a global let that
+// shadows a builtin. They never make compilation fail.
+func (c *Compiler) Warnings() []string {
+	return c.warnings
+}
+
+// Bytecode returns a snapshot of the compiled program. The slices are copied,
+// so compiling more code with the same compiler, as the REPL does, never
+// changes bytecode that was handed out earlier.
 func (c *Compiler) Bytecode() *Bytecode {
+	instructions := make(code.Instructions, len(c.currentInstructions()))
+	copy(instructions, c.currentInstructions())
+
+	constants := make([]object.Object, len(c.constants))
+	copy(constants, c.constants)
+
+	var lineInfo []int
+	if c.lineInfo {
+		lineInfo = make([]int, len(c.scopes[c.scopeIndex].lines))
+		copy(lineInfo, c.scopes[c.scopeIndex].lines)
+	}
+
 	return &Bytecode{
-		Instructions: c.currentInstructions(),
-		Constants:    c.constants,
+		Instructions: instructions,
+		Constants:    constants,
+		LineInfo:     lineInfo,
 	}
 }
 
@@ -73,7 +200,66 @@ func (c *Compiler) currentInstructions() code.Instructions {
 	return c.scopes[c.scopeIndex].instructions
 }
 
+// CompileInto compiles a top-level node, typically an *ast.Program, on top of
+// everything compiled so far. Calls share the constant pool and the global
+// symbol table and append to the main instructions, so Bytecode returns the
+// combined program. If compilation fails the instructions, constants, global
+// definitions and warnings are rolled back to where they were before the call.
+func (c *Compiler) CompileInto(node ast.Node) error {
+	if c.scopeIndex != 0 {
+		return fmt.Errorf("CompileInto called inside a function scope")
+	}
+
+	scope := c.scopes[0]
+	symbolTable := c.symbolTable
+	store := maps.Clone(symbolTable.store)
+	parameters := maps.Clone(symbolTable.parameters)
+	numDefinitions := symbolTable.numberOfDefinitions
+	numWarnings := len(c.warnings)
+	numConstants := len(c.constants)
+	imports := maps.Clone(c.imports)
+
+	error := c.Compile(node)
+	if error != nil {
+		c.imports = imports
+		c.importChain = nil
+		c.scopes = c.scopes[:1]
+		c.scopes[0] = scope
+		c.scopeIndex = 0
+		c.symbolTable = symbolTable
+		symbolTable.store = store
+		symbolTable.parameters = parameters
+		symbolTable.numberOfDefinitions = numDefinitions
+		c.warnings = c.warnings[:numWarnings]
+		c.truncateConstants(numConstants)
+
+		return error
+	}
+
+	return nil
+}
+
+// truncateConstants removes the constants added after the first length ones,
+// along with their entries in the deduplication maps.
+func (c *Compiler) truncateConstants(length int) {
+	for _, constant := range c.constants[length:] {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			delete(c.integerConstants, constant.Value)
+		case *object.String:
+			delete(c.stringConstants, constant.Value)
+		}
+	}
+	c.constants = c.constants[:length]
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
+	if line := ast.Line(node); c.lineInfo && line > 0 {
+		outer := c.line
+		c.line = line
+		defer func() { c.line = outer }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, statement := range node.Statements {
@@ -91,6 +277,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.BlockStatement:
+		c.blockDepth++
+		defer func() { c.blockDepth-- }()
+
 		for _, statement := range node.Statements {
 			error := c.Compile(statement)
 			if error != nil {
@@ -99,18 +288,39 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		// A function literal may refer to its own name, so that is defined up
+		// front. Other values are compiled before the name exists, so that
+		// `let x = x;` refers to an outer x instead of the slot being set.
+		var symbol Symbol
+
+		if existing, ok := c.symbolTable.store[node.Name.Value]; ok && existing.Scope == BuiltinScope {
+			c.warnings = append(c.warnings, fmt.Sprintf("line %d: let %s shadows the builtin of the same name",
+				node.Token.Line, node.Name.Value))
+		}
+
+		fn, isFunction := node.Value.(*ast.FunctionLiteral)
+		if isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
+			c.symbolTable.SetParameters(node.Name.Value, parameterNames(fn))
+		}
+
 		error := c.Compile(node.Value)
 		if error != nil {
 			return error
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+		if !isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
 		}
 
+		c.storeSymbol(symbol)
+
+	case *ast.DebuggerStatement:
+		c.emit(code.OpBreak)
+
+	case *ast.ImportStatement:
+		return c.compileImport(node)
+
 	case *ast.ReturnStatement:
 		error := c.Compile(node.ReturnValue)
 		if error != nil {
@@ -120,6 +330,17 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpReturnValue)
 
 	case *ast.InfixExpression:
+		if c.folding {
+			if value, ok := foldInteger(node); ok {
+				c.emitInteger(value)
+				return nil
+			}
+		}
+
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+
 		if node.Operator == "<" {
 			error := c.Compile(node.Right)
 			if error != nil {
@@ -153,6 +374,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -164,6 +387,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.PrefixExpression:
+		if c.folding {
+			if value, ok := foldInteger(node); ok {
+				c.emitInteger(value)
+				return nil
+			}
+		}
+
 		error := c.Compile(node.Right)
 		if error != nil {
 			return error
@@ -179,6 +409,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.IfExpression:
+		if condition, ok := node.Condition.(*ast.Boolean); ok && c.folding {
+			return c.compileTakenBranch(node, condition.Value)
+		}
+
 		error := c.Compile(node.Condition)
 		if error != nil {
 			return error
@@ -191,9 +425,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return error
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.keepBlockValue()
 
 		jumpPos := c.emit(code.OpJump, 9999)
 
@@ -208,14 +440,66 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return error
 			}
 
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.keepBlockValue()
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
 
+	case *ast.WhileExpression:
+		loopStartPos := len(c.currentInstructions())
+
+		error := c.Compile(node.Condition)
+		if error != nil {
+			return error
+		}
+
+		jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+
+		error = c.Compile(node.Body)
+		if error != nil {
+			return error
+		}
+
+		c.emit(code.OpLoop, loopStartPos)
+
+		afterBodyPos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruePos, afterBodyPos)
+
+		c.emit(code.OpNull)
+
+	case *ast.ForeachExpression:
+		return c.compileForeach(node)
+
+	case *ast.TryExpression:
+		setupCatchPos := c.emit(code.OpSetupCatch, 9999)
+
+		error := c.Compile(node.Body)
+		if error != nil {
+			return error
+		}
+
+		c.keepBlockValue()
+		c.emit(code.OpPopCatch)
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		// The VM enters the handler with the error message on the stack.
+		handlerPos := len(c.currentInstructions())
+		c.changeOperand(setupCatchPos, handlerPos)
+
+		c.storeSymbol(c.symbolTable.Define(node.Parameter.Value))
+
+		error = c.Compile(node.Handler)
+		if error != nil {
+			return error
+		}
+
+		c.keepBlockValue()
+
+		afterHandlerPos := len(c.currentInstructions())
+		c.changeOperand(jumpPos, afterHandlerPos)
+
 	case *ast.IndexExpression:
 		error := c.Compile(node.Left)
 		if error != nil {
@@ -235,18 +519,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return error
 		}
 
-		for _, argument := range node.Arguments {
-			error := c.Compile(argument)
-			if error != nil {
-				return error
-			}
+		arguments, error := c.callArguments(node)
+		if error != nil {
+			return error
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		error = c.compileArguments(node, arguments)
+		if error != nil {
+			return error
+		}
+
+		c.emit(code.OpCall, len(arguments))
 
 	case *ast.IntegerLiteral:
-		integer := &object.Integer{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(integer))
+		c.emitInteger(node.Value)
+
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
 
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
@@ -263,15 +555,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for key := range node.Pairs {
-			keys = append(keys, key)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-
-		for _, key := range keys {
+		for _, key := range node.OrderedKeys() {
 			error := c.Compile(key)
 			if error != nil {
 				return error
@@ -289,6 +573,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		if node.Name != "" {
 			c.symbolTable.DefineFunctionName(node.Name)
+			c.symbolTable.SetParameters(node.Name, parameterNames(node))
 		}
 
 		for _, parameter := range node.Parameters {
@@ -309,6 +594,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numberOfDefinitions
+		lines := c.scopes[c.scopeIndex].lines
 		instructions := c.leaveScope()
 
 		for _, symbol := range freeSymbols {
@@ -319,6 +605,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			LineInfo:      lines,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
@@ -343,12 +630,315 @@ func (c *Compiler) Compile(node ast.Node) error {
 }
 
 func (c *Compiler) addConstant(obj object.Object) int {
+	integer, isInteger := obj.(*object.Integer)
+	if isInteger {
+		if index, ok := c.integerConstants[integer.Value]; ok {
+			return index
+		}
+	}
+
+	str, isString := obj.(*object.String)
+	if isString {
+		if index, ok := c.stringConstants[str.Value]; ok {
+			return index
+		}
+	}
+
+	// Identical function literals share a constant; each evaluation still
+	// creates its own closure.
+	if fn, ok := obj.(*object.CompiledFunction); ok {
+		for index, constant := range c.constants {
+			if other, ok := constant.(*object.CompiledFunction); ok && fn.Equal(other) {
+				return index
+			}
+		}
+	}
+
 	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+	index := len(c.constants) - 1
+
+	if isInteger {
+		c.integerConstants[integer.Value] = index
+	}
+	if isString {
+		c.stringConstants[str.Value] = index
+	}
+
+	return index
+}
+
+// compileImport compiles the file named by node into the global scope, so its
+// top-level bindings become globals of the importing program. A file is only
+// compiled the first time it is imported.
+func (c *Compiler) compileImport(node *ast.ImportStatement) error {
+	// An import inside a block might never run, yet the file would count as
+	// imported from then on.
+	if c.scopeIndex != 0 || c.blockDepth != 0 {
+		return fmt.Errorf("import is only allowed at the top level: %s", node.Path)
+	}
+
+	if node.Path != filepath.Base(node.Path) || node.Path == "." || node.Path == ".." {
+		return fmt.Errorf("import must name a file in the same directory: %s", node.Path)
+	}
+
+	inProgress, seen := c.imports[node.Path]
+	if inProgress {
+		chain := append(c.importChain, node.Path)
+		return fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+	}
+	if seen {
+		return nil
+	}
+
+	source, error := os.ReadFile(filepath.Join(c.importDir, node.Path))
+	if error != nil {
+		return fmt.Errorf("cannot import %s: %s", node.Path, error)
+	}
+
+	parser := parser.New(lexer.New(string(source)))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) != 0 {
+		return fmt.Errorf("cannot import %s: %s", node.Path, parser.Errors()[0])
+	}
+
+	c.imports[node.Path] = true
+	c.importChain = append(c.importChain, node.Path)
+
+	error = c.Compile(program)
+
+	c.imports[node.Path] = false
+	c.importChain = c.importChain[:len(c.importChain)-1]
+
+	if error != nil {
+		delete(c.imports, node.Path)
+		return fmt.Errorf("in %s: %s", node.Path, error)
+	}
+
+	return nil
+}
+
+// compileForeach lowers a foreach loop to an index-based loop over the keys of
+// the iterable, which OpIterKeys produces along with their count. Arrays are
+// keyed by index, so indexing the iterable with the key yields the value for
+// both arrays and hashes.
+func (c *Compiler) compileForeach(node *ast.ForeachExpression) error {
+	c.foreachDepth++
+	defer func() { c.foreachDepth-- }()
+
+	// The '$' keeps these names out of reach of the program.
+	hidden := func(name string) Symbol {
+		return c.symbolTable.Define(fmt.Sprintf("$foreach%d.%s", c.foreachDepth, name))
+	}
+
+	error := c.Compile(node.Iterable)
+	if error != nil {
+		return error
+	}
+
+	iterable := hidden("iterable")
+	keys := hidden("keys")
+	count := hidden("count")
+	index := hidden("index")
+
+	c.storeSymbol(iterable)
+	c.loadSymbol(iterable)
+	c.emit(code.OpIterKeys)
+	c.storeSymbol(count)
+	c.storeSymbol(keys)
+	c.emitInteger(0)
+	c.storeSymbol(index)
+
+	loopStartPos := len(c.currentInstructions())
+
+	c.loadSymbol(count)
+	c.loadSymbol(index)
+	c.emit(code.OpGreaterThan)
+
+	jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+
+	key := hidden("key")
+	if node.Key != nil {
+		key = c.symbolTable.Define(node.Key.Value)
+	}
+
+	c.loadSymbol(keys)
+	c.loadSymbol(index)
+	c.emit(code.OpIndex)
+	c.storeSymbol(key)
+
+	c.loadSymbol(iterable)
+	c.loadSymbol(key)
+	c.emit(code.OpIndex)
+	c.storeSymbol(c.symbolTable.Define(node.Value.Value))
+
+	error = c.Compile(node.Body)
+	if error != nil {
+		return error
+	}
+
+	c.loadSymbol(index)
+	c.emitInteger(1)
+	c.emit(code.OpAdd)
+	c.storeSymbol(index)
+
+	c.emit(code.OpLoop, loopStartPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruePos, afterBodyPos)
+
+	c.emit(code.OpNull)
+
+	return nil
+}
+
+// callArguments returns the arguments of node in positional order. Keyword
+// arguments can only be placed when the callee's parameter names are known at
+// compile time, that is for function literals and names bound to them.
+func (c *Compiler) callArguments(node *ast.CallExpression) ([]ast.Expression, error) {
+	if !node.HasKeywordArguments() {
+		return node.Arguments, nil
+	}
+
+	var parameters []string
+	known := false
+
+	switch function := node.Function.(type) {
+	case *ast.FunctionLiteral:
+		parameters, known = parameterNames(function), true
+	case *ast.Identifier:
+		parameters, known = c.symbolTable.Parameters(function.Value)
+	}
+
+	if !known {
+		return nil, fmt.Errorf("keyword arguments need a function known at compile time, got %s",
+			node.Function.String())
+	}
+
+	return node.PositionalArguments(parameters)
+}
+
+// compileArguments pushes arguments, the arguments of node in positional order.
+// They are still evaluated left to right as written: when keyword arguments
+// reorder them, each value is kept in a hidden variable first and the variables
+// are loaded in parameter order.
+func (c *Compiler) compileArguments(node *ast.CallExpression, arguments []ast.Expression) error {
+	written := make([]ast.Expression, len(node.Arguments))
+	for index, argument := range node.Arguments {
+		if keyword, ok := argument.(*ast.KeywordArgument); ok {
+			argument = keyword.Value
+		}
+		written[index] = argument
+	}
+
+	if slices.Equal(written, arguments) {
+		for _, argument := range arguments {
+			error := c.Compile(argument)
+			if error != nil {
+				return error
+			}
+		}
+
+		return nil
+	}
+
+	c.keywordCallDepth++
+	defer func() { c.keywordCallDepth-- }()
+
+	hidden := make(map[ast.Expression]Symbol, len(written))
+	for index, argument := range written {
+		error := c.Compile(argument)
+		if error != nil {
+			return error
+		}
+
+		// The '$' keeps these names out of reach of the program.
+		symbol := c.symbolTable.Define(fmt.Sprintf("$call%d.%d", c.keywordCallDepth, index))
+		c.storeSymbol(symbol)
+		hidden[argument] = symbol
+	}
+
+	for _, argument := range arguments {
+		c.loadSymbol(hidden[argument])
+	}
+
+	return nil
+}
+
+func parameterNames(fn *ast.FunctionLiteral) []string {
+	names := make([]string, len(fn.Parameters))
+	for index, parameter := range fn.Parameters {
+		names[index] = parameter.Value
+	}
+
+	return names
+}
+
+// emitInteger loads value onto the stack, inline when it fits an OpSmallInt
+// operand and through the constant pool otherwise.
+func (c *Compiler) emitInteger(value int64) {
+	if value >= 0 && value <= MaxSmallInt {
+		c.emit(code.OpSmallInt, int(value))
+		return
+	}
+
+	c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: value}))
+}
+
+// foldInteger evaluates node at compile time when it is integer arithmetic or
+// negation on literals. Division by zero and results outside the int64 range
+// are left for the VM.
+func foldInteger(node ast.Expression) (int64, bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return node.Value, true
+
+	case *ast.PrefixExpression:
+		if node.Operator != "-" {
+			return 0, false
+		}
+
+		value, ok := foldInteger(node.Right)
+		if !ok {
+			return 0, false
+		}
+
+		if integer, ok := object.NegateInteger(object.NewInteger(value)).(*object.Integer); ok {
+			return integer.Value, true
+		}
+
+	case *ast.InfixExpression:
+		left, ok := foldInteger(node.Left)
+		if !ok {
+			return 0, false
+		}
+
+		right, ok := foldInteger(node.Right)
+		if !ok {
+			return 0, false
+		}
+
+		switch node.Operator {
+		case "+", "-", "*", "/", "%":
+			if (node.Operator == "/" || node.Operator == "%") && right == 0 {
+				return 0, false
+			}
+
+			result := object.IntegerOperation(node.Operator, &object.Integer{Value: left}, &object.Integer{Value: right})
+			if integer, ok := result.(*object.Integer); ok {
+				return integer.Value, true
+			}
+		}
+	}
+
+	return 0, false
 }
 
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	instruction := code.Make(op, operands...)
+	instruction, error := code.MakeChecked(op, operands...)
+	if error != nil {
+		panic(fmt.Sprintf("compiler emitted invalid instruction: %s", error))
+	}
+
 	position := c.addInstruction(instruction)
 	c.setLastInstruction(op, position)
 	return position
@@ -360,6 +950,12 @@ func (c *Compiler) addInstruction(instruction []byte) int {
 
 	c.scopes[c.scopeIndex].instructions = updatedInstructions
 
+	if c.lineInfo {
+		for range instruction {
+			c.scopes[c.scopeIndex].lines = append(c.scopes[c.scopeIndex].lines, c.line)
+		}
+	}
+
 	return positionOfNewInstruction
 }
 
@@ -388,6 +984,136 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	if c.lineInfo {
+		c.scopes[c.scopeIndex].lines = c.scopes[c.scopeIndex].lines[:last.Position]
+	}
+}
+
+// compileLogical compiles && and || so that the right operand only runs when
+// the left one does not already decide the result. Either way the result is
+// the boolean truthiness of the last operand evaluated.
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	error := c.Compile(node.Left)
+	if error != nil {
+		return error
+	}
+
+	// A falsy left operand decides &&, a truthy one decides ||.
+	if node.Operator == "||" {
+		c.emit(code.OpBang)
+	}
+	shortCircuitPos := c.emit(code.OpJumpNotTrue, 9999)
+
+	error = c.Compile(node.Right)
+	if error != nil {
+		return error
+	}
+
+	rightFalsyPos := c.emit(code.OpJumpNotTrue, 9999)
+
+	truePos := c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	falsePos := c.emit(code.OpFalse)
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	c.changeOperand(rightFalsyPos, falsePos)
+	if node.Operator == "||" {
+		c.changeOperand(shortCircuitPos, truePos)
+	} else {
+		c.changeOperand(shortCircuitPos, falsePos)
+	}
+
+	return nil
+}
+
+// compileTakenBranch compiles the branch of node selected by a literal
+// condition, leaving its value on the stack like a full if expression would.
+// The other branch is still checked, so folding never accepts a program that
+// would not compile without it.
+func (c *Compiler) compileTakenBranch(node *ast.IfExpression, condition bool) error {
+	branch, untaken := node.Alternative, node.Consequence
+	if condition {
+		branch, untaken = node.Consequence, node.Alternative
+	}
+
+	// Branches are compiled in source order, so that names they define get
+	// the same slots as without folding.
+	if !condition {
+		error := c.checkUntakenBranch(untaken)
+		if error != nil {
+			return error
+		}
+	}
+
+	error := c.compileBranchValue(branch)
+	if error != nil {
+		return error
+	}
+
+	if condition {
+		return c.checkUntakenBranch(untaken)
+	}
+
+	return nil
+}
+
+// checkUntakenBranch compiles branch only for the errors it reports and then
+// discards its instructions and constants. Names it defines stay defined, as
+// they would be had the branch been compiled.
+func (c *Compiler) checkUntakenBranch(branch *ast.BlockStatement) error {
+	if branch == nil {
+		return nil
+	}
+
+	scope := c.scopes[c.scopeIndex]
+	numConstants := len(c.constants)
+
+	error := c.Compile(branch)
+	if error != nil {
+		return error
+	}
+
+	c.scopes[c.scopeIndex] = scope
+	c.truncateConstants(numConstants)
+
+	return nil
+}
+
+// compileBranchValue compiles branch and leaves its value on the stack, or
+// null when there is no branch.
+func (c *Compiler) compileBranchValue(branch *ast.BlockStatement) error {
+	if branch == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	start := len(c.currentInstructions())
+
+	error := c.Compile(branch)
+	if error != nil {
+		return error
+	}
+
+	// An empty branch emits nothing, and the last instruction is then the
+	// OpPop of the previous statement, which keepBlockValue must not remove.
+	if len(c.currentInstructions()) == start {
+		c.emit(code.OpNull)
+	} else {
+		c.keepBlockValue()
+	}
+
+	return nil
+}
+
+// keepBlockValue leaves the value of a just-compiled branch on the stack. Blocks
+// that produce no value, such as empty ones or ones ending in a let, push null.
+func (c *Compiler) keepBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
 }
 
 func (c *Compiler) replaceInstruction(position int, newInstruction []byte) {
@@ -447,3 +1173,11 @@ func (c *Compiler) loadSymbol(sym Symbol) {
         c.emit(code.OpCurrentClosure)
 	}
 }
+
+func (c *Compiler) storeSymbol(sym Symbol) {
+	if sym.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, sym.Index)
+	} else {
+		c.emit(code.OpSetLocal, sym.Index)
+	}
+}
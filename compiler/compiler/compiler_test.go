@@ -7,6 +7,9 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -19,10 +22,16 @@ type compilerTestCase struct {
 func runCompilerTests(tester *testing.T, tests []compilerTestCase) {
 	tester.Helper()
 
+	runCompilerTestsWith(tester, tests, func() *Compiler { return New() })
+}
+
+func runCompilerTestsWith(tester *testing.T, tests []compilerTestCase, newCompiler func() *Compiler) {
+	tester.Helper()
+
 	for _, testcase := range tests {
 		program := parse(testcase.input)
 
-		compiler := New()
+		compiler := newCompiler()
 		error := compiler.Compile(program)
 		if error != nil {
 			tester.Fatalf("compiler error: %s", error)
@@ -145,59 +154,69 @@ func TestIntegerArithmetic(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "1+2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpAdd),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1; 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpPop),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1 - 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpSub),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1 * 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpMul),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "2 / 1",
-			expectedConstants: []interface{}{2, 1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpDiv),
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "7 % 3",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 7),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "-1",
-			expectedConstants: []interface{}{1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpMinus),
 				code.Make(code.OpPop),
 			},
@@ -207,6 +226,322 @@ func TestIntegerArithmetic(tester *testing.T) {
 	runCompilerTests(tester, tests)
 }
 
+func TestSmallIntegerLiterals(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "0; 255; 256",
+			expectedConstants: []interface{}{256},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpSmallInt, 255),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[7, 1000][1]",
+			expectedConstants: []interface{}{1000},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 7),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestIntegerConstantDeduplication(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1000; 2000; 1000",
+			expectedConstants: []interface{}{1000, 2000},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+
+	compiler := NewWithState(NewSymbolTable(), []object.Object{&object.Integer{Value: 1000}})
+	error := compiler.Compile(parse("1000"))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	error = testConstants([]interface{}{1000}, compiler.Bytecode().Constants)
+	if error != nil {
+		tester.Fatalf("constant from previous state was not reused: %s", error)
+	}
+}
+
+func TestFunctionConstantDeduplication(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { 1 }; fn() { 2 }; fn() { 1 }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpSmallInt, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpSmallInt, 2),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestConstantFolding(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "-5",
+			expectedConstants: []interface{}{-5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 * -3; -(1 + 2)",
+			expectedConstants: []interface{}{-6, -3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "--5",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 5),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-true",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "60 * 60 - 100 / 4",
+			expectedConstants: []interface{}{3575},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1000; 500 + 500",
+			expectedConstants: []interface{}{1000},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "999 + 1; 1000",
+			expectedConstants: []interface{}{1000},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 / 0",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 0),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "17 % 5",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 % 0",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 0),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpGreaterThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let x = 1; x + 2 * 3",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSmallInt, 6),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTestsWith(tester, tests, func() *Compiler {
+		compiler := New()
+		compiler.SetFolding(true)
+		return compiler
+	})
+}
+
+func TestFoldingConstantConditions(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 1 } else { 2 }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (false) { 1 } else { 2 }; 3",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (false) { 1 }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1; if (true) { }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { let x = 1; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `if (false) { "dead" } else { 1000 }`,
+			expectedConstants: []interface{}{1000},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (false) { let x = 1; }; let y = 2;",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+	}
+
+	runCompilerTestsWith(tester, tests, func() *Compiler {
+		compiler := New()
+		compiler.SetFolding(true)
+		return compiler
+	})
+}
+
+func TestFoldingChecksUntakenBranch(tester *testing.T) {
+	inputs := []string{
+		"if (false) { undefinedVar }",
+		"if (true) { 1 } else { undefinedVar }",
+		"if (false) { fn() { undefinedVar } } else { 1 }",
+	}
+
+	for _, input := range inputs {
+		for _, folding := range []bool{false, true} {
+			compiler := New()
+			compiler.SetFolding(folding)
+
+			error := compiler.Compile(parse(input))
+			if error == nil || error.Error() != "undefined variable undefinedVar" {
+				tester.Errorf("wrong error for %q with folding=%t. got=%v", input, folding, error)
+			}
+		}
+	}
+}
+
 func TestBooleanExpressions(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -227,40 +562,40 @@ func TestBooleanExpressions(tester *testing.T) {
 		},
 		{
 			input:             "1 > 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpGreaterThan),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []interface{}{2, 1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpGreaterThan),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1 == 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpEqual),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "1 != 2",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpNotEqual),
 				code.Make(code.OpPop),
 			},
@@ -299,33 +634,218 @@ func TestBooleanExpressions(tester *testing.T) {
 	runCompilerTests(tester, tests)
 }
 
+func TestDebuggerStatements(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "debugger; 1",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpBreak),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { debugger; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTrue, 9),
+				// 0004
+				code.Make(code.OpBreak),
+				// 0005
+				code.Make(code.OpNull),
+				// 0006
+				code.Make(code.OpJump, 10),
+				// 0009
+				code.Make(code.OpNull),
+				// 0010
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestLogicalOperators(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTrue, 12),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTrue, 12),
+				// 0008
+				code.Make(code.OpTrue),
+				// 0009
+				code.Make(code.OpJump, 13),
+				// 0012
+				code.Make(code.OpFalse),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpBang),
+				// 0002
+				code.Make(code.OpJumpNotTrue, 9),
+				// 0005
+				code.Make(code.OpFalse),
+				// 0006
+				code.Make(code.OpJumpNotTrue, 13),
+				// 0009
+				code.Make(code.OpTrue),
+				// 0010
+				code.Make(code.OpJump, 14),
+				// 0013
+				code.Make(code.OpFalse),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
 func TestConditionals(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "if (true) { 10 }; 3333;",
-			expectedConstants: []interface{}{10, 3333},
+			expectedConstants: []interface{}{3333},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpTrue),
-				code.Make(code.OpJumpNotTrue, 10),
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpJump, 11),
+				code.Make(code.OpJumpNotTrue, 9),
+				code.Make(code.OpSmallInt, 10),
+				code.Make(code.OpJump, 10),
 				code.Make(code.OpNull),
 				code.Make(code.OpPop),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "if (true) { 10 } else { 20 }; 3333;",
-			expectedConstants: []interface{}{10, 20, 3333},
+			expectedConstants: []interface{}{3333},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTrue, 9),
+				code.Make(code.OpSmallInt, 10),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpSmallInt, 20),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestWhileExpressions(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `while (true) { 10 }; 3333;`,
+			expectedConstants: []interface{}{3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
 				code.Make(code.OpJumpNotTrue, 10),
+				// 0004
+				code.Make(code.OpSmallInt, 10),
+				// 0006
+				code.Make(code.OpPop),
+				// 0007
+				code.Make(code.OpLoop, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpJump, 13),
-				code.Make(code.OpConstant, 1),
+				// 0015
 				code.Make(code.OpPop),
-				code.Make(code.OpConstant, 2),
+			},
+		},
+		{
+			input:             `until (true) { }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpBang),
+				// 0002
+				code.Make(code.OpJumpNotTrue, 8),
+				// 0005
+				code.Make(code.OpLoop, 0),
+				// 0008
+				code.Make(code.OpNull),
+				// 0009
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestTryExpressions(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `try { 1 } catch (e) { e }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupCatch, 9),
+				// 0003
+				code.Make(code.OpSmallInt, 1),
+				// 0005
+				code.Make(code.OpPopCatch),
+				// 0006
+				code.Make(code.OpJump, 15),
+				// 0009
+				code.Make(code.OpSetGlobal, 0),
+				// 0012
+				code.Make(code.OpGetGlobal, 0),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `try { } catch (e) { }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupCatch, 8),
+				// 0003
+				code.Make(code.OpNull),
+				// 0004
+				code.Make(code.OpPopCatch),
+				// 0005
+				code.Make(code.OpJump, 12),
+				// 0008
+				code.Make(code.OpSetGlobal, 0),
+				// 0011
+				code.Make(code.OpNull),
+				// 0012
 				code.Make(code.OpPop),
 			},
 		},
@@ -338,19 +858,19 @@ func TestGlobalLetStatements(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "let one = 1; let two = 2;",
-			expectedConstants: []interface{}{1, 2},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpSetGlobal, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpSetGlobal, 1),
 			},
 		},
 		{
 			input:             "let one = 1; one;",
-			expectedConstants: []interface{}{1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpPop),
@@ -358,9 +878,9 @@ func TestGlobalLetStatements(tester *testing.T) {
 		},
 		{
 			input:             "let one = 1; let two = one; two;",
-			expectedConstants: []interface{}{1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpSetGlobal, 1),
@@ -393,9 +913,34 @@ func TestStringExpressions(tester *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"x"; "y"; "x" + "x"`,
+			expectedConstants: []interface{}{"x", "y"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(tester, tests)
+
+	compiler := NewWithState(NewSymbolTable(), []object.Object{&object.String{Value: "x"}})
+	error := compiler.Compile(parse(`"x"`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	error = testConstants([]interface{}{"x"}, compiler.Bytecode().Constants)
+	if error != nil {
+		tester.Fatalf("string constant from previous state was not reused: %s", error)
+	}
 }
 
 func TestArrayLiterals(tester *testing.T) {
@@ -410,27 +955,27 @@ func TestArrayLiterals(tester *testing.T) {
 		},
 		{
 			input:             "[1, 2, 3]",
-			expectedConstants: []interface{}{1, 2, 3},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 3),
 				code.Make(code.OpArray, 3),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "[1 + 2, 3 - 4, 5 * 6]",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpSmallInt, 4),
 				code.Make(code.OpSub),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
+				code.Make(code.OpSmallInt, 5),
+				code.Make(code.OpSmallInt, 6),
 				code.Make(code.OpMul),
 				code.Make(code.OpArray, 3),
 				code.Make(code.OpPop),
@@ -453,30 +998,51 @@ func TestHashLiterals(tester *testing.T) {
 		},
 		{
 			input:             "{1: 2, 3: 4, 5: 6}",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpSmallInt, 4),
+				code.Make(code.OpSmallInt, 5),
+				code.Make(code.OpSmallInt, 6),
 				code.Make(code.OpHash, 6),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "{1: 2 + 3, 4: 5 * 6}",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSmallInt, 4),
+				code.Make(code.OpSmallInt, 5),
+				code.Make(code.OpSmallInt, 6),
+				code.Make(code.OpMul),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestHashLiteralsKeepSourceOrder(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let k = "a"; {k: 1, "b": 2}`,
+			expectedConstants: []interface{}{"a", "b"},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpMul),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpHash, 4),
 				code.Make(code.OpPop),
 			},
@@ -490,14 +1056,14 @@ func TestIndexExpressions(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "[1, 2, 3][1 + 1]",
-			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 3),
 				code.Make(code.OpArray, 3),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpAdd),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
@@ -505,13 +1071,13 @@ func TestIndexExpressions(tester *testing.T) {
 		},
 		{
 			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []interface{}{1, 2, 2, 1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
 				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpSub),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
@@ -527,48 +1093,45 @@ func TestFunctions(tester *testing.T) {
 		{
 			input: "fn() { return 5 + 10 }",
 			expectedConstants: []interface{}{
-				5, 10,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSmallInt, 5),
+					code.Make(code.OpSmallInt, 10),
 					code.Make(code.OpAdd),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: "fn() { 5 + 10 }",
 			expectedConstants: []interface{}{
-				5, 10,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSmallInt, 5),
+					code.Make(code.OpSmallInt, 10),
 					code.Make(code.OpAdd),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: "fn() { 1; 2 }",
 			expectedConstants: []interface{}{
-				1, 2,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 1),
 					code.Make(code.OpPop),
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSmallInt, 2),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
@@ -577,6 +1140,187 @@ func TestFunctions(tester *testing.T) {
 	runCompilerTests(tester, tests)
 }
 
+func TestBytecodeIsNotAliased(tester *testing.T) {
+	compiler := New()
+	error := compiler.Compile(parse("1000; 2"))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	first := compiler.Bytecode()
+
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpSmallInt, 2),
+		code.Make(code.OpPop),
+	}
+
+	error = compiler.Compile(parse("if (true) { 3000 }"))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	second := compiler.Bytecode()
+
+	error = testInstructions(expectedInstructions, first.Instructions)
+	if error != nil {
+		tester.Fatalf("first bytecode changed by second compile: %s", error)
+	}
+
+	error = testConstants([]interface{}{1000}, first.Constants)
+	if error != nil {
+		tester.Fatalf("first bytecode changed by second compile: %s", error)
+	}
+
+	first.Instructions[0] = byte(code.OpNull)
+	first.Constants[0] = &object.Integer{Value: -1}
+
+	if second.Instructions[0] != byte(code.OpConstant) {
+		tester.Errorf("second bytecode shares instructions with the first")
+	}
+
+	error = testConstants([]interface{}{1000, 3000}, second.Constants)
+	if error != nil {
+		tester.Errorf("second bytecode shares constants with the first: %s", error)
+	}
+}
+
+func TestCompileIntoRollsBackOnError(tester *testing.T) {
+	compiler := New()
+
+	error := compiler.CompileInto(parse("let x = 1000;"))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	error = compiler.CompileInto(parse("2000; fn() { 3000; y }"))
+	if error == nil {
+		tester.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	error = compiler.CompileInto(parse("x + 2000"))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	bytecode := compiler.Bytecode()
+
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpAdd),
+		code.Make(code.OpPop),
+	}
+
+	error = testInstructions(expectedInstructions, bytecode.Instructions)
+	if error != nil {
+		tester.Fatalf("testInstructions failed: %s", error)
+	}
+
+	error = testConstants([]interface{}{1000, 2000}, bytecode.Constants)
+	if error != nil {
+		tester.Fatalf("testConstants failed: %s", error)
+	}
+
+	if compiler.scopeIndex != 0 || compiler.symbolTable.Outer != nil {
+		tester.Errorf("compiler was left inside the failed function's scope")
+	}
+}
+
+func TestCompileIntoRollsBackWarnings(tester *testing.T) {
+	compiler := New()
+
+	error := compiler.CompileInto(parse("let len = 1; undefined"))
+	if error == nil {
+		tester.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	if warnings := compiler.Warnings(); len(warnings) != 0 {
+		tester.Errorf("warnings of the failed compile were kept: %q", warnings)
+	}
+}
+
+func TestLineInfo(tester *testing.T) {
+	input := `let a = 1;
+let b = a
+  * 2;
+let f = fn() {
+  b / 0
+};
+f()`
+
+	compiler := New()
+	compiler.SetLineInfo(true)
+
+	error := compiler.Compile(parse(input))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	bytecode := compiler.Bytecode()
+	if len(bytecode.LineInfo) != len(bytecode.Instructions) {
+		tester.Fatalf("wrong LineInfo length. want=%d, got=%d",
+			len(bytecode.Instructions), len(bytecode.LineInfo))
+	}
+
+	expected := []struct {
+		instruction []byte
+		line        int
+	}{
+		{code.Make(code.OpSmallInt, 1), 1},
+		{code.Make(code.OpSetGlobal, 0), 1},
+		{code.Make(code.OpGetGlobal, 0), 2},
+		{code.Make(code.OpSmallInt, 2), 3},
+		{code.Make(code.OpMul), 3},
+		{code.Make(code.OpSetGlobal, 1), 2},
+		{code.Make(code.OpClosure, 0, 0), 4},
+		{code.Make(code.OpSetGlobal, 2), 4},
+		{code.Make(code.OpGetGlobal, 2), 7},
+		{code.Make(code.OpCall, 0), 7},
+		{code.Make(code.OpPop), 7},
+	}
+
+	offset := 0
+	for i, want := range expected {
+		got := bytecode.Instructions[offset : offset+len(want.instruction)]
+		if string(got) != string(want.instruction) {
+			tester.Fatalf("instruction %d wrong. want=%q, got=%q", i,
+				code.Instructions(want.instruction), code.Instructions(got))
+		}
+
+		if bytecode.LineInfo[offset] != want.line {
+			tester.Errorf("wrong line for %q at %04d. want=%d, got=%d",
+				code.Instructions(want.instruction), offset, want.line, bytecode.LineInfo[offset])
+		}
+
+		offset += len(want.instruction)
+	}
+
+	fn, ok := bytecode.Constants[0].(*object.CompiledFunction)
+	if !ok {
+		tester.Fatalf("constant 0 is not a function. got=%T", bytecode.Constants[0])
+	}
+
+	// OpGetGlobal 1, OpSmallInt 0, OpDiv, OpReturnValue, all on line 5.
+	for offset, line := range fn.LineInfo {
+		if line != 5 {
+			tester.Errorf("wrong line for function offset %d. want=5, got=%d", offset, line)
+		}
+	}
+
+	if len(fn.LineInfo) != len(fn.Instructions) {
+		tester.Errorf("wrong function LineInfo length. want=%d, got=%d",
+			len(fn.Instructions), len(fn.LineInfo))
+	}
+
+	if New().Bytecode().LineInfo != nil {
+		tester.Errorf("LineInfo recorded without SetLineInfo")
+	}
+}
+
 func TestCompilerScopes(tester *testing.T) {
 	compiler := New()
 	if compiler.scopeIndex != 0 {
@@ -652,6 +1396,20 @@ func TestFunctionsWithoutReturnValue(tester *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: "fn() { let x = 1; }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpSmallInt, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(tester, tests)
@@ -662,14 +1420,13 @@ func TestFunctionCalls(tester *testing.T) {
 		{
 			input: "fn() { 24 }();",
 			expectedConstants: []interface{}{
-				24,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 24),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpCall, 0),
 				code.Make(code.OpPop),
 			},
@@ -677,14 +1434,13 @@ func TestFunctionCalls(tester *testing.T) {
 		{
 			input: "let noArg = fn() { 24 }; noArg();",
 			expectedConstants: []interface{}{
-				24,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 24),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpCall, 0),
@@ -698,13 +1454,12 @@ func TestFunctionCalls(tester *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpReturnValue),
 				},
-				24,
 			},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSmallInt, 24),
 				code.Make(code.OpCall, 1),
 				code.Make(code.OpPop),
 			},
@@ -720,66 +1475,227 @@ func TestFunctionCalls(tester *testing.T) {
 					code.Make(code.OpGetLocal, 2),
 					code.Make(code.OpReturnValue),
 				},
-				24, 25, 26,
 			},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSmallInt, 24),
+				code.Make(code.OpSmallInt, 25),
+				code.Make(code.OpSmallInt, 26),
+				code.Make(code.OpCall, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(tester, tests)
+}
+
+func TestKeywordArguments(tester *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `let sub = fn(x, y) { x - y }; sub(y: 1, x: 2);`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpSub),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(a, b, c) { a }(1, c: 3, b: 2);`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpSmallInt, 3),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpGetGlobal, 1),
 				code.Make(code.OpCall, 3),
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `fn(a, b) { a }(a: 1, b: 2);`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSmallInt, 1),
+				code.Make(code.OpSmallInt, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(tester, tests)
 }
 
+func TestWithoutBuiltins(tester *testing.T) {
+	compiler := New(WithoutBuiltins())
+	error := compiler.Compile(parse(`len("")`))
+	if error == nil {
+		tester.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	if error.Error() != "undefined variable len" {
+		tester.Errorf("wrong compiler error. want=%q, got=%q", "undefined variable len", error)
+	}
+
+	compiler = New(WithoutBuiltins())
+	error = compiler.Compile(parse(`let len = fn(x) { 0 }; len("")`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	if warnings := compiler.Warnings(); len(warnings) != 0 {
+		tester.Errorf("expected no warnings without builtins. got=%q", warnings)
+	}
+}
+
+func TestLetInitializerCannotReadItself(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { let x = x; }", "undefined variable x"},
+		{"fn() { let x = x + 1; x }", "undefined variable x"},
+		{"let y = y;", "undefined variable y"},
+	}
+
+	for _, testcase := range tests {
+		compiler := New()
+		error := compiler.Compile(parse(testcase.input))
+		if error == nil {
+			tester.Fatalf("expected compiler error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong compiler error for %q. want=%q, got=%q",
+				testcase.input, testcase.expected, error)
+		}
+	}
+
+	runCompilerTests(tester, []compilerTestCase{
+		{
+			input: "fn() { let x = 1; let x = x + 1; x }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpSmallInt, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSmallInt, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
+
+func TestKeywordArgumentErrors(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(x, y) { x }; f(1, x: 2);`, "multiple values for argument x"},
+		{`let f = fn(x, y) { x }; f(x: 1, z: 2);`, "unexpected keyword argument z"},
+		{`let f = fn(x, y) { x }; f(y: 2);`, "missing argument x"},
+		{`let f = fn(x) { x }; f(1, 2, x: 3);`, "too many arguments: want=1, got=3"},
+		{`let f = fn(x) { x }; let f = 1; f(x: 1);`,
+			"keyword arguments need a function known at compile time, got f"},
+		{`len(x: [])`, "keyword arguments need a function known at compile time, got len"},
+	}
+
+	for _, testcase := range tests {
+		compiler := New()
+		error := compiler.Compile(parse(testcase.input))
+		if error == nil {
+			tester.Fatalf("expected compiler error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong compiler error. want=%q, got=%q", testcase.expected, error)
+		}
+	}
+}
+
 func TestLetStatementScopes(tester *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input: "let num = 55; fn() { num }",
 			expectedConstants: []interface{}{
-				55,
 				[]code.Instructions{
 					code.Make(code.OpGetGlobal, 0),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 55),
 				code.Make(code.OpSetGlobal, 0),
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: "fn() { let num = 55; num }",
 			expectedConstants: []interface{}{
-				55,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 55),
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: "fn() { let a = 55; let b = 77; a + b }",
 			expectedConstants: []interface{}{
-				55, 77,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 55),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSmallInt, 77),
 					code.Make(code.OpSetLocal, 1),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpGetLocal, 1),
@@ -788,7 +1704,7 @@ func TestLetStatementScopes(tester *testing.T) {
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpPop),
 			},
 		},
@@ -804,7 +1720,7 @@ func TestBuiltins(tester *testing.T) {
             len([]);
             push([], 1);
             `,
-			expectedConstants: []interface{}{1},
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpGetBuiltin, 0),
 				code.Make(code.OpArray, 0),
@@ -812,7 +1728,7 @@ func TestBuiltins(tester *testing.T) {
 				code.Make(code.OpPop),
 				code.Make(code.OpGetBuiltin, 5),
 				code.Make(code.OpArray, 0),
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpCall, 2),
 				code.Make(code.OpPop),
 			},
@@ -902,9 +1818,8 @@ func TestClosures(tester *testing.T) {
             }
             `,
 			expectedConstants: []interface{}{
-				55, 66, 77, 88,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 3),
+					code.Make(code.OpSmallInt, 88),
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetGlobal, 0),
 					code.Make(code.OpGetFree, 0),
@@ -916,25 +1831,25 @@ func TestClosures(tester *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpConstant, 2),
+					code.Make(code.OpSmallInt, 77),
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetFree, 0),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpClosure, 4, 2),
+					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSmallInt, 66),
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpClosure, 5, 1),
+					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSmallInt, 55),
 				code.Make(code.OpSetGlobal, 0),
-				code.Make(code.OpClosure, 6, 0),
+				code.Make(code.OpClosure, 2, 0),
 				code.Make(code.OpPop),
 			},
 		},
@@ -951,22 +1866,20 @@ func TestRecursiveFunctions(tester *testing.T) {
             countDown(1);
             `,
 			expectedConstants: []interface{}{
-				1,
 				[]code.Instructions{
 					code.Make(code.OpCurrentClosure),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 1),
 					code.Make(code.OpSub),
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
-				1,
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpClosure, 0, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSmallInt, 1),
 				code.Make(code.OpCall, 1),
 				code.Make(code.OpPop),
 			},
@@ -980,27 +1893,25 @@ func TestRecursiveFunctions(tester *testing.T) {
             wrapper();
             `,
 			expectedConstants: []interface{}{
-				1,
 				[]code.Instructions{
 					code.Make(code.OpCurrentClosure),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSmallInt, 1),
 					code.Make(code.OpSub),
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
-				1,
 				[]code.Instructions{
-					code.Make(code.OpClosure, 1, 0),
+					code.Make(code.OpClosure, 0, 0),
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 2),
+					code.Make(code.OpSmallInt, 1),
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpClosure, 1, 0),
 				code.Make(code.OpSetGlobal, 0),
 				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpCall, 0),
@@ -1011,3 +1922,89 @@ func TestRecursiveFunctions(tester *testing.T) {
 
 	runCompilerTests(tester, tests)
 }
+
+func TestImportErrors(tester *testing.T) {
+	dir := tester.TempDir()
+	files := map[string]string{
+		"a.monkey":      `import "b.monkey"; let a = 1;`,
+		"b.monkey":      `import "a.monkey"; let b = 2;`,
+		"broken.monkey": `let = 1;`,
+		"undef.monkey":  `let u = missing;`,
+	}
+	for name, source := range files {
+		error := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644)
+		if error != nil {
+			tester.Fatalf("writing %s: %s", name, error)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "a.monkey";`, "in a.monkey: in b.monkey: import cycle: a.monkey -> b.monkey -> a.monkey"},
+		{`import "../a.monkey";`, "import must name a file in the same directory: ../a.monkey"},
+		{`import "sub/a.monkey";`, "import must name a file in the same directory: sub/a.monkey"},
+		{`import "missing.monkey";`, "cannot import missing.monkey: open " +
+			filepath.Join(dir, "missing.monkey") + ": no such file or directory"},
+		{`import "broken.monkey";`, "cannot import broken.monkey: expected next token to be IDENT, got = instead"},
+		{`import "undef.monkey";`, "in undef.monkey: undefined variable missing"},
+		{`fn() { import "a.monkey"; }`, "import is only allowed at the top level: a.monkey"},
+		{`if (false) { import "a.monkey" }`, "import is only allowed at the top level: a.monkey"},
+		{`while (false) { import "a.monkey"; }`, "import is only allowed at the top level: a.monkey"},
+		{`try { import "a.monkey"; } catch (e) { 0 }`, "import is only allowed at the top level: a.monkey"},
+	}
+
+	for _, testcase := range tests {
+		compiler := New()
+		compiler.SetImportDir(dir)
+
+		error := compiler.Compile(parse(testcase.input))
+		if error == nil {
+			tester.Fatalf("expected compiler error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong compiler error for %q. want=%q, got=%q",
+				testcase.input, testcase.expected, error)
+		}
+	}
+}
+
+func TestBuiltinShadowingWarning(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let len = 5; len", []string{"line 1: let len shadows the builtin of the same name"}},
+		{"let len = 5;\nlet len = 6;", []string{"line 1: let len shadows the builtin of the same name"}},
+		{"let puts = fn(x) { x };", []string{"line 1: let puts shadows the builtin of the same name"}},
+		{"let length = 5;", nil},
+		{"let f = fn() { let len = 1; len };", nil},
+	}
+
+	for _, test := range tests {
+		compiler := New()
+		error := compiler.Compile(parse(test.input))
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		if !slices.Equal(compiler.Warnings(), test.expected) {
+			tester.Errorf("wrong warnings for %q. want=%q, got=%q", test.input, test.expected, compiler.Warnings())
+		}
+	}
+
+	runCompilerTests(tester, []compilerTestCase{
+		{
+			input:             "let len = 5; len",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpSmallInt, 5),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	})
+}
@@ -22,13 +22,18 @@ type SymbolTable struct {
 	store               map[string]Symbol
 	numberOfDefinitions int
 
+	// parameters holds the parameter names of symbols bound to function
+	// literals, which is what keyword arguments are resolved against.
+	parameters map[string][]string
+
 	FreeSymbols []Symbol
 }
 
 func NewSymbolTable() *SymbolTable {
 	store := make(map[string]Symbol)
 	free := []Symbol{}
-	return &SymbolTable{store: store, FreeSymbols: free}
+	parameters := make(map[string][]string)
+	return &SymbolTable{store: store, FreeSymbols: free, parameters: parameters}
 }
 
 func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
@@ -45,7 +50,15 @@ func (st *SymbolTable) Define(name string) Symbol {
 		symbol.Scope = LocalScope
 	}
 
+	// Defining a name again in the same scope rebinds its existing slot, which
+	// lets loops update a variable with `let i = i + 1`.
+	if existing, ok := st.store[name]; ok && existing.Scope == symbol.Scope {
+		delete(st.parameters, name)
+		return existing
+	}
+
 	st.store[name] = symbol
+	delete(st.parameters, name)
 	st.numberOfDefinitions++
 
 	return symbol
@@ -90,6 +103,28 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 func (st *SymbolTable) DefineFunctionName(name string) Symbol {
 	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
 	st.store[name] = symbol
+	delete(st.parameters, name)
 
 	return symbol
 }
+
+// SetParameters records the parameter names of the function bound to name.
+func (st *SymbolTable) SetParameters(name string, parameters []string) {
+	st.parameters[name] = parameters
+}
+
+// Parameters returns the parameter names recorded for name in the scope that
+// defines it.
+func (st *SymbolTable) Parameters(name string) ([]string, bool) {
+	symbol, ok := st.store[name]
+	if !ok || symbol.Scope == FreeScope {
+		if st.Outer == nil {
+			return nil, false
+		}
+
+		return st.Outer.Parameters(name)
+	}
+
+	parameters, ok := st.parameters[name]
+	return parameters, ok
+}
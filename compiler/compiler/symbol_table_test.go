@@ -1,6 +1,9 @@
 package compiler
 
-import "testing"
+import (
+	"slices"
+	"testing"
+)
 
 func TestDefine(tester *testing.T) {
 	expected := map[string]Symbol{
@@ -308,3 +311,74 @@ func TestShadowFunctionName(tester *testing.T) {
 		tester.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
 	}
 }
+
+func TestParameters(tester *testing.T) {
+	global := NewSymbolTable()
+	global.Define("f")
+	global.SetParameters("f", []string{"x", "y"})
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("g")
+	local.SetParameters("g", []string{"a"})
+
+	nested := NewEnclosedSymbolTable(local)
+	nested.Resolve("g")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected []string
+	}{
+		{global, "f", []string{"x", "y"}},
+		{local, "f", []string{"x", "y"}},
+		{nested, "f", []string{"x", "y"}},
+		{nested, "g", []string{"a"}},
+	}
+
+	for _, testcase := range tests {
+		parameters, ok := testcase.table.Parameters(testcase.name)
+		if !ok {
+			tester.Errorf("no parameters for %s", testcase.name)
+			continue
+		}
+
+		if !slices.Equal(parameters, testcase.expected) {
+			tester.Errorf("wrong parameters for %s. want=%v, got=%v",
+				testcase.name, testcase.expected, parameters)
+		}
+	}
+
+	local.Define("f")
+	if _, ok := nested.Parameters("f"); ok {
+		tester.Errorf("parameters of shadowed f still visible")
+	}
+}
+
+func TestRedefineReusesSlot(tester *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	expected := Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	if result := global.Define("a"); result != expected {
+		tester.Errorf("expected a=%+v, got=%+v", expected, result)
+	}
+
+	global.DefineBuiltin(0, "len")
+	expected = Symbol{Name: "len", Scope: GlobalScope, Index: 2}
+	if result := global.Define("len"); result != expected {
+		tester.Errorf("expected len=%+v, got=%+v", expected, result)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	local.Resolve("a")
+
+	expected = Symbol{Name: "a", Scope: LocalScope, Index: 0}
+	if result := local.Define("a"); result != expected {
+		tester.Errorf("expected local a=%+v, got=%+v", expected, result)
+	}
+
+	if result := local.Define("a"); result != expected {
+		tester.Errorf("expected redefined local a=%+v, got=%+v", expected, result)
+	}
+}
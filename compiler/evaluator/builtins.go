@@ -3,10 +3,45 @@ package evaluator
 import "monkey/object"
 
 var builtins = map[string]*object.Builtin{
-	"len":   object.GetBuiltinByName("len"),
-	"first": object.GetBuiltinByName("first"),
-	"last":  object.GetBuiltinByName("last"),
-	"rest":  object.GetBuiltinByName("rest"),
-	"push":  object.GetBuiltinByName("push"),
-	"puts":  object.GetBuiltinByName("puts"),
+	"len":        object.GetBuiltinByName("len"),
+	"first":      object.GetBuiltinByName("first"),
+	"last":       object.GetBuiltinByName("last"),
+	"rest":       object.GetBuiltinByName("rest"),
+	"push":       object.GetBuiltinByName("push"),
+	"puts":       object.GetBuiltinByName("puts"),
+	"upper":      object.GetBuiltinByName("upper"),
+	"lower":      object.GetBuiltinByName("lower"),
+	"partition":  object.GetBuiltinByName("partition"),
+	"group_by":   object.GetBuiltinByName("group_by"),
+	"string":     object.GetBuiltinByName("string"),
+	"take":       object.GetBuiltinByName("take"),
+	"drop":       object.GetBuiltinByName("drop"),
+	"keys":       object.GetBuiltinByName("keys"),
+	"debug":      object.GetBuiltinByName("debug"),
+	"find":       object.GetBuiltinByName("find"),
+	"find_index": object.GetBuiltinByName("find_index"),
+	"str":        object.GetBuiltinByName("str"),
+	"repeat":     object.GetBuiltinByName("repeat"),
+	"matches":    object.GetBuiltinByName("matches"),
+	"find_all":   object.GetBuiltinByName("find_all"),
+	"min_by":     object.GetBuiltinByName("min_by"),
+	"max_by":     object.GetBuiltinByName("max_by"),
+	"error":      object.GetBuiltinByName("error"),
+	"is_error":   object.GetBuiltinByName("is_error"),
+	"sprintf":    object.GetBuiltinByName("sprintf"),
+	"bool":       object.GetBuiltinByName("bool"),
+	"floordiv":   object.GetBuiltinByName("floordiv"),
+	"zip_with":   object.GetBuiltinByName("zip_with"),
+	"unique":     object.GetBuiltinByName("unique"),
+	"set":        object.GetBuiltinByName("set"),
+	"set_add":    object.GetBuiltinByName("set_add"),
+	"set_has":    object.GetBuiltinByName("set_has"),
+	"set_remove": object.GetBuiltinByName("set_remove"),
+	"assert":     object.GetBuiltinByName("assert"),
+	"chunk":      object.GetBuiltinByName("chunk"),
+	"scan":       object.GetBuiltinByName("scan"),
+	"prefix_sum": object.GetBuiltinByName("prefix_sum"),
+	"each":       object.GetBuiltinByName("each"),
+	"freeze":     object.GetBuiltinByName("freeze"),
+	"divmod":     object.GetBuiltinByName("divmod"),
 }
@@ -6,12 +6,6 @@ import (
 	"monkey/object"
 )
 
-var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-)
-
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
@@ -27,6 +21,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return value
 		}
 		return &object.ReturnValue{Value: value}
+	case *ast.ImportStatement:
+		return newError("import is only supported by the compiler: %s", node.Path)
+	case *ast.DebuggerStatement:
+		// The evaluator has no debugger hook; the statement does nothing.
+		return nil
 	case *ast.LetStatement:
 		value := Eval(node.Value, env)
 		if isError(value) {
@@ -41,8 +40,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
-		return nativeBoolToBooleanObject(node.Value)
+		return object.NativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -55,6 +58,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, left, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
@@ -62,6 +69,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.WhileExpression:
+		return evalWhileExpression(node, env)
+	case *ast.ForeachExpression:
+		return evalForeachExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.CallExpression:
@@ -69,12 +82,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(function) {
 			return function
 		}
-		arguments := evalExpressions(node.Arguments, env)
+		argumentNodes, error := callArguments(node, function)
+		if error != nil {
+			return error
+		}
+
+		// is_error has to receive the error its argument evaluates to instead
+		// of stopping on it. Fatal errors still end the evaluation.
+		if function == builtins["is_error"] && len(argumentNodes) == 1 {
+			argument := Eval(argumentNodes[0], env)
+			if isError(argument) && argument.(*object.Error).Fatal {
+				return argument
+			}
+
+			return applyFunction(function, argument)
+		}
+
+		arguments := evalCallArguments(node, argumentNodes, env)
 		if len(arguments) == 1 && isError(arguments[0]) {
 			return arguments[0]
 		}
 
-		return applyFunction(function, arguments)
+		return applyFunction(function, arguments...)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -145,37 +174,33 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return object.NativeBoolToBooleanObject(!right.Truthy())
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJECT {
+	if !object.IsNumber(right) {
 		return newError("unknown operator: -%s", right.Type())
 	}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return object.NegateNumber(right)
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT:
-		return evalIntegerInfixExpression(operator, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evalNumberInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return object.NativeBoolToBooleanObject(left == right)
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return object.NativeBoolToBooleanObject(left != right)
+	case (operator == "<" || operator == ">") && object.IsFunction(left):
+		return newError("unsupported operand type for comparison: %s", left.Type())
+	case (operator == "<" || operator == ">") && object.IsFunction(right):
+		return newError("unsupported operand type for comparison: %s", right.Type())
+	case operator == "+" && (left.Type() == object.STRING_OBJECT) != (right.Type() == object.STRING_OBJECT):
+		return newError("cannot concatenate %s and %s (use str())", left.Type(), right.Type())
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -183,27 +208,23 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
+func evalNumberInfixExpression(operator string, left, right object.Object) object.Object {
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
-	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
-	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
-	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+	case "/", "%":
+		if object.IsZero(right) {
+			return newError("division by zero")
+		}
+		return object.NumberOperation(operator, left, right)
+	case "+", "-", "*":
+		return object.NumberOperation(operator, left, right)
 	case "<":
-		return nativeBoolToBooleanObject(leftValue < rightValue)
+		return object.NativeBoolToBooleanObject(object.CompareNumbers(left, right) < 0)
 	case ">":
-		return nativeBoolToBooleanObject(leftValue > rightValue)
+		return object.NativeBoolToBooleanObject(object.CompareNumbers(left, right) > 0)
 	case "==":
-		return nativeBoolToBooleanObject(leftValue == rightValue)
+		return object.NativeBoolToBooleanObject(object.CompareNumbers(left, right) == 0)
 	case "!=":
-		return nativeBoolToBooleanObject(leftValue != rightValue)
+		return object.NativeBoolToBooleanObject(object.CompareNumbers(left, right) != 0)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -219,19 +240,103 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftValue + rightValue}
 }
 
+// evalLogicalExpression only evaluates the right operand of && and || when the
+// left one does not already decide the result, which is always a boolean.
+func evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if left.Truthy() == (node.Operator == "||") {
+		return object.NativeBoolToBooleanObject(left.Truthy())
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return object.NativeBoolToBooleanObject(right.Truthy())
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
-	if isTruthy(condition) {
+	if condition.Truthy() {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
 		return Eval(ie.Alternative, env)
 	} else {
-		return NULL
+		return object.NULL
+	}
+}
+
+func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		condition := Eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !condition.Truthy() {
+			return object.NULL
+		}
+
+		result := Eval(we.Body, env)
+		if result != nil {
+			returnType := result.Type()
+			if returnType == object.RETURN_VALUE_OBJECT || returnType == object.ERROR_OBJECT {
+				return result
+			}
+		}
+	}
+}
+
+func evalForeachExpression(fe *ast.ForeachExpression, env *object.Environment) object.Object {
+	iterable := Eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var pairs []object.HashPair
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, element := range iterable.Elements {
+			pairs = append(pairs, object.HashPair{Key: &object.Integer{Value: int64(i)}, Value: element})
+		}
+	case *object.Hash:
+		pairs = iterable.OrderedPairs()
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, pair := range pairs {
+		if fe.Key != nil {
+			env.Set(fe.Key.Value, pair.Key)
+		}
+		env.Set(fe.Value.Value, pair.Value)
+
+		result := Eval(fe.Body, env)
+		if result != nil {
+			returnType := result.Type()
+			if returnType == object.RETURN_VALUE_OBJECT || returnType == object.ERROR_OBJECT {
+				return result
+			}
+		}
 	}
+
+	return object.NULL
+}
+
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Body, env)
+	if !isError(result) || result.(*object.Error).Fatal {
+		return result
+	}
+
+	env.Set(te.Parameter.Value, &object.String{Value: result.(*object.Error).Chain()})
+
+	return Eval(te.Handler, env)
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
@@ -251,19 +356,6 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
-		return false
-	default:
-		return true
-	}
-}
-
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if value, ok := env.Get(node.Value); ok {
 		return value
@@ -276,7 +368,65 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
-func applyFunction(fn object.Object, arguments []object.Object) object.Object {
+// callArguments returns the arguments of node in positional order, placing
+// keyword arguments by the parameter names of function.
+func callArguments(node *ast.CallExpression, function object.Object) ([]ast.Expression, *object.Error) {
+	if !node.HasKeywordArguments() {
+		return node.Arguments, nil
+	}
+
+	fn, ok := function.(*object.Function)
+	if !ok {
+		return nil, newError("keyword arguments are not supported for %s", function.Type())
+	}
+
+	parameters := make([]string, len(fn.Parameters))
+	for index, parameter := range fn.Parameters {
+		parameters[index] = parameter.Value
+	}
+
+	arguments, error := node.PositionalArguments(parameters)
+	if error != nil {
+		return nil, newError("%s", error)
+	}
+
+	return arguments, nil
+}
+
+// evalCallArguments evaluates the arguments of node left to right as written
+// and returns their values in the positional order of argumentNodes.
+func evalCallArguments(node *ast.CallExpression, argumentNodes []ast.Expression, env *object.Environment) []object.Object {
+	if !node.HasKeywordArguments() {
+		return evalExpressions(argumentNodes, env)
+	}
+
+	written := make([]ast.Expression, len(node.Arguments))
+	for index, argument := range node.Arguments {
+		if keyword, ok := argument.(*ast.KeywordArgument); ok {
+			argument = keyword.Value
+		}
+		written[index] = argument
+	}
+
+	values := evalExpressions(written, env)
+	if len(values) == 1 && isError(values[0]) {
+		return values
+	}
+
+	byNode := make(map[ast.Expression]object.Object, len(written))
+	for index, argument := range written {
+		byNode[argument] = values[index]
+	}
+
+	arguments := make([]object.Object, len(argumentNodes))
+	for index, argument := range argumentNodes {
+		arguments[index] = byNode[argument]
+	}
+
+	return arguments
+}
+
+func applyFunction(fn object.Object, arguments ...object.Object) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
 		extendedEnv := extendFunctionEnv(function, arguments)
@@ -284,11 +434,18 @@ func applyFunction(fn object.Object, arguments []object.Object) object.Object {
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-        if result := function.Fn(arguments...); result != nil {
-            return result
-        }
+		var result object.Object
+		if function.HigherOrderFn != nil {
+			result = function.HigherOrderFn(applyFunction, arguments...)
+		} else {
+			result = function.Fn(arguments...)
+		}
 
-		return NULL
+		if result != nil {
+			return result
+		}
+
+		return object.NULL
 	default:
 		return newError("not a function: %s", function.Type())
 	}
@@ -329,41 +486,52 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	max := int64(len(arr.Elements) - 1)
 
 	if idx < 0 || idx > max {
-		return NULL
+		return object.NULL
 	}
 
 	return arr.Elements[idx]
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
 
-	for keyNode, valueNode := range node.Pairs {
+	for index, keyNode := range node.OrderedKeys() {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
+		if object.IsMutableArray(key) {
+			return newError("cannot use mutable array as hash key")
+		}
+
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError("unusable as hash key: %s at pair %d", key.Type(), index+1)
+		}
+
+		if _, exists := hash.Pairs[hashKey.HashKey()]; exists && env.Strict() {
+			return newError("duplicate hash key at runtime")
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
+	if object.IsMutableArray(index) {
+		return newError("cannot use mutable array as hash key")
+	}
+
 	key, ok := index.(object.Hashable)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
@@ -371,20 +539,12 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
-		return NULL
+		return object.NULL
 	}
 
 	return pair.Value
 }
 
-func nativeBoolToBooleanObject(input bool) *object.Boolean {
-	if input {
-		return TRUE
-	}
-
-	return FALSE
-}
-
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
@@ -27,6 +27,9 @@ func TestEvalIntegerExpression(tester *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	for _, testcase := range tests {
@@ -35,6 +38,53 @@ func TestEvalIntegerExpression(tester *testing.T) {
 	}
 }
 
+func TestBigIntegers(tester *testing.T) {
+	input := `let factorial = fn(n) { if (n < 2) { 1 } else { n * factorial(n - 1) } }; factorial(25)`
+
+	evaluated := testEval(input)
+	bigInt, ok := evaluated.(*object.BigInt)
+	if !ok {
+		tester.Fatalf("object is not BigInt. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if bigInt.Inspect() != "15511210043330985984000000" {
+		tester.Errorf("wrong factorial. got=%s", bigInt.Inspect())
+	}
+
+	testIntegerObject(tester, testEval(input+" / factorial(23)"), 600)
+	testBooleanObject(tester, testEval("99999999999999999999 > 9223372036854775807"), true)
+}
+
+func TestFloatExpressions(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"1.0 / 2", 0.5},
+		{"1 / 2.0", 0.5},
+		{"1 + 0.5", 1.5},
+		{"-2.5 * 2", -5},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		float, ok := evaluated.(*object.Float)
+		if !ok {
+			tester.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if float.Value != testcase.expected {
+			tester.Errorf("wrong value for %q. want=%g, got=%g", testcase.input, testcase.expected, float.Value)
+		}
+	}
+
+	testIntegerObject(tester, testEval("1 / 2"), 0)
+	testBooleanObject(tester, testEval("2.0 == 2"), true)
+	testBooleanObject(tester, testEval("1.5 > 1"), true)
+}
+
 func TestEvalBooleanExpression(tester *testing.T) {
 	tests := []struct {
 		input    string
@@ -67,6 +117,31 @@ func TestEvalBooleanExpression(tester *testing.T) {
 	}
 }
 
+func TestLogicalOperators(tester *testing.T) {
+	crashy := `let crashyFn = fn() { 1 / 0 }; `
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && false", false},
+		{"false || true", true},
+		{`1 && "monkey"`, true},
+		{"if (false) { 1 } || 0", true},
+		{crashy + "false && crashyFn()", false},
+		{crashy + "true || crashyFn()", true},
+	}
+
+	for _, testcase := range tests {
+		testBooleanObject(tester, testEval(testcase.input), testcase.expected)
+	}
+
+	evaluated := testEval(crashy + "true && crashyFn()")
+	if err, ok := evaluated.(*object.Error); !ok || err.Message != "division by zero" {
+		tester.Errorf("right operand error not returned. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestBangOperator(tester *testing.T) {
 	tests := []struct {
 		input    string
@@ -111,6 +186,89 @@ func TestIfElseExpression(tester *testing.T) {
 	}
 }
 
+func TestLoopsAndUnless(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let i = 0; until (i > 4) { let i = i + 1; }; i`, 5},
+		{`let i = 0; let sum = 0; while (i < 5) { let i = i + 1; let sum = sum + i; }; sum`, 15},
+		{`while (false) { 1 }`, nil},
+		{`let first = fn() { let i = 0; while (true) { if (i == 3) { return i; }; let i = i + 1; } }; first()`, 3},
+		{`unless (1 > 2) { 10 }`, 10},
+		{`unless (1 < 2) { 10 } else { 20 }`, 20},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		integer, ok := testcase.expected.(int)
+		if ok {
+			testIntegerObject(tester, evaluated, int64(integer))
+		} else {
+			testNullObject(tester, evaluated)
+		}
+	}
+}
+
+func TestForeachExpressions(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sum = 0; foreach (x in [1, 2, 3, 4]) { let sum = sum + x; }; sum`, 10},
+		{`let s = ""; foreach (k, v in {"a": "x", "b": "y", "c": "z"}) { let s = s + k + v; }; s`, "axbycz"},
+		{`let sum = 0; foreach (i, x in [10, 20, 30]) { let sum = sum + i * x; }; sum`, 80},
+		{`foreach (x in []) { x }`, nil},
+		{`foreach (x in 5) { x }`, "cannot iterate over INTEGER"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		switch expected := testcase.expected.(type) {
+		case int:
+			testIntegerObject(tester, evaluated, int64(expected))
+		case string:
+			if errorObject, ok := evaluated.(*object.Error); ok {
+				if errorObject.Message != expected {
+					tester.Errorf("wrong error message. expected=%q, got=%q", expected, errorObject.Message)
+				}
+				continue
+			}
+			testStringObject(tester, evaluated, expected)
+		default:
+			testNullObject(tester, evaluated)
+		}
+	}
+}
+
+func TestTryExpressions(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 + true } catch (e) { -1 }`, -1},
+		{`try { 1 + true } catch (e) { e }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 10 } catch (e) { -1 }`, 10},
+		{`let f = fn() { foobar }; try { f() } catch (e) { e }`, "identifier not found: foobar"},
+		{`try { find([0], fn(x) { 1 / x }) } catch (e) { e }`, "callback to `find` failed (caused by: division by zero)"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		switch expected := testcase.expected.(type) {
+		case int:
+			testIntegerObject(tester, evaluated, int64(expected))
+		case string:
+			testStringObject(tester, evaluated, expected)
+		}
+	}
+
+	evaluated := testEval(`try { assert(false, "fatal") } catch (e) { 0 }`)
+	if errorObject, ok := evaluated.(*object.Error); !ok || errorObject.Message != "assertion failed: fatal" {
+		tester.Errorf("a failed assert was caught. got=%s", evaluated.Inspect())
+	}
+}
+
 func TestReturnStatements(tester *testing.T) {
 	tests := []struct {
 		input    string
@@ -156,6 +314,22 @@ func TestErrorHandling(tester *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"5 / 0",
+			"division by zero",
+		},
+		{
+			"5 / (1 - 1)",
+			"division by zero",
+		},
+		{
+			"5 % 0",
+			"division by zero",
+		},
+		{
+			"1.5 / 0",
+			"division by zero",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -191,6 +365,30 @@ func TestErrorHandling(tester *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{"name": "Monkey", fn(x) { x }: 1};`,
+			"unusable as hash key: FUNCTION at pair 2",
+		},
+		{
+			`{[1, 2]: 1};`,
+			"cannot use mutable array as hash key",
+		},
+		{
+			`fn() { 1 } > 1`,
+			"unsupported operand type for comparison: FUNCTION",
+		},
+		{
+			`1 < len`,
+			"unsupported operand type for comparison: BUILTIN",
+		},
+		{
+			`"count: " + 5`,
+			"cannot concatenate STRING and INTEGER (use str())",
+		},
+		{
+			`5 + "count"`,
+			"cannot concatenate INTEGER and STRING (use str())",
+		},
 	}
 
 	for _, testcase := range tests {
@@ -268,6 +466,41 @@ func TestFunctionCalling(tester *testing.T) {
 	}
 }
 
+func TestKeywordArguments(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let sub = fn(x, y) { x - y }; sub(y: 1, x: 3);`, 2},
+		{`let f = fn(a, b, c) { a * 100 + b * 10 + c }; f(1, c: 3, b: 2);`, 123},
+		{`let f = fn(x) { x }; f(y: 1);`, "unexpected keyword argument y"},
+		{`len(x: "")`, "keyword arguments are not supported for BUILTIN"},
+		{`let sub = fn(x, y) { x - y }; sub(y: sub(y: 1, x: 5), x: 10);`, 6},
+		{`let sub = fn(x, y) { x - y }; sub(y: 1 / 0, x: -true);`, "division by zero"},
+		{`let sub = fn(x, y) { x - y }; sub(y: -true, x: 1 / 0);`, "unknown operator: -BOOLEAN"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+
+		switch expected := testcase.expected.(type) {
+		case int:
+			testIntegerObject(tester, evaluated, int64(expected))
+		case string:
+			errorObject, ok := evaluated.(*object.Error)
+			if !ok {
+				tester.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errorObject.Message != expected {
+				tester.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errorObject.Message)
+			}
+		}
+	}
+}
+
 func TestStringLiteral(tester *testing.T) {
 	input := `"Hello World!"`
 
@@ -306,6 +539,13 @@ func TestBuiltinFunctions(tester *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`error("boom")`, "boom"},
+		{`let f = fn() { error("boom"); 1 }; f()`, "boom"},
+		{`try { error("boom") } catch (e) { len(e) }`, 4},
+		{`is_error(error("x"))`, true},
+		{`is_error(len(1))`, true},
+		{`is_error(1)`, false},
+		{`is_error(assert(false, "x"))`, "assertion failed: x"},
 	}
 
 	for _, testcase := range tests {
@@ -314,6 +554,8 @@ func TestBuiltinFunctions(tester *testing.T) {
 		switch expected := testcase.expected.(type) {
 		case int:
 			testIntegerObject(tester, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(tester, evaluated, expected)
 		case string:
 			errorObject, ok := evaluated.(*object.Error)
 			if !ok {
@@ -405,6 +647,31 @@ func TestArrayIndexExpressions(tester *testing.T) {
 	}
 }
 
+func TestStrictDuplicateHashKeys(tester *testing.T) {
+	input := `let first = "a"; let second = "a"; let h = {first: 1, second: 2}; h[first]`
+
+	testIntegerObject(tester, testEval(input), 2)
+
+	env := object.NewEnvironment()
+	env.SetStrict(true)
+
+	evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		tester.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Message != "duplicate hash key at runtime" {
+		tester.Errorf("wrong error message. expected=%q, got=%q", "duplicate hash key at runtime", errObj.Message)
+	}
+
+	input = `let f = fn(key) { {key: 1, "a": 2} }; f("a")`
+	evaluated = Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	if !isError(evaluated) {
+		tester.Errorf("strict mode was not inherited by the function's environment. got=%s", evaluated.Inspect())
+	}
+}
+
 func TestHashLiterals(tester *testing.T) {
 	input := `let two = "two";
     {
@@ -427,8 +694,8 @@ func TestHashLiterals(tester *testing.T) {
 		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
 		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+		object.TRUE.HashKey():                      5,
+		object.FALSE.HashKey():                     6,
 	}
 
 	if len(result.Pairs) != len(expected) {
@@ -445,6 +712,42 @@ func TestHashLiterals(tester *testing.T) {
 	}
 }
 
+func TestHashKeysInsertionOrder(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({})`, "[]"},
+		{`keys({"b": 1, "a": 2, "c": 3})`, "[b, a, c]"},
+		{`keys({3: 1, 1: 2, 2: 3})`, "[3, 1, 2]"},
+		{`keys({"z": 1, "y": 2, "z": 3})`, "[z, y]"},
+		{`{"b": 1, "a": 2}`, "{b: 1, a: 2}"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		if evaluated.Inspect() != testcase.expected {
+			tester.Errorf("wrong result. want=%q, got=%q", testcase.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDebugBuiltin(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`debug(5)`, "*object.Integer{Value: 5}"},
+		{`debug([1, "two", [3]])`, "*object.Array{Len: 3, Elements: [INTEGER, STRING, ARRAY]}"},
+		{`debug(fn(a, b) { a + b })`, "*object.Function{NumParameters: 2}"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		testStringObject(tester, evaluated, testcase.expected)
+	}
+}
+
 func TestHashIndexExpressions(tester *testing.T) {
 	tests := []struct {
 		input    string
@@ -470,6 +773,10 @@ func TestHashIndexExpressions(tester *testing.T) {
 			`{5: 5}[5]`,
 			5,
 		},
+		{
+			`{freeze([1, "a"]): 5}[freeze([1, "a"])]`,
+			5,
+		},
 		{
 			`{true: 5}[true]`,
 			5,
@@ -532,9 +839,25 @@ func testBooleanObject(tester *testing.T, obj object.Object, expected bool) bool
 	return true
 }
 
+func testStringObject(tester *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		tester.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		tester.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testNullObject(tester *testing.T, obj object.Object) bool {
-	if obj != NULL {
-		tester.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+	if obj != object.NULL {
+		tester.Errorf("object is not object.NULL. got=%T (%+v)", obj, obj)
 		return false
 	}
 
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"strings"
 )
 
 type Lexer struct {
@@ -9,15 +10,25 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	peeked []token.Token // tokens scanned by PeekTokenN but not yet returned
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	lexer := &Lexer{input: input, line: 1}
 	lexer.readChar()
 	return lexer
 }
 
 func (lexer *Lexer) readChar() {
+	if lexer.atLineBreak() {
+		lexer.line++
+		lexer.column = 0
+	}
+	lexer.column++
+
 	if lexer.readPosition >= len(lexer.input) {
 		lexer.ch = 0
 	} else {
@@ -28,10 +39,51 @@ func (lexer *Lexer) readChar() {
 }
 
 func (lexer *Lexer) NextToken() token.Token {
-	var tok token.Token
+	if len(lexer.peeked) > 0 {
+		tok := lexer.peeked[0]
+		lexer.peeked = lexer.peeked[1:]
+		return tok
+	}
 
+	return lexer.scanToken()
+}
+
+// PeekToken returns the token the next call to NextToken will return without
+// consuming it.
+func (lexer *Lexer) PeekToken() token.Token {
+	return lexer.PeekTokenN(1)
+}
+
+// PeekTokenN returns the nth upcoming token without consuming it, so that
+// PeekTokenN(1) is the same as PeekToken. Past the end of the input it returns
+// EOF tokens. It panics if n is less than 1.
+func (lexer *Lexer) PeekTokenN(n int) token.Token {
+	if n < 1 {
+		panic("lexer: PeekTokenN needs n >= 1")
+	}
+
+	for len(lexer.peeked) < n {
+		lexer.peeked = append(lexer.peeked, lexer.scanToken())
+	}
+
+	return lexer.peeked[n-1]
+}
+
+func (lexer *Lexer) scanToken() token.Token {
 	lexer.skipWhitspace()
 
+	line, column := lexer.line, lexer.column
+
+	tok := lexer.readToken()
+	tok.Line = line
+	tok.Column = column
+
+	return tok
+}
+
+func (lexer *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch lexer.ch {
 	case ';':
 		tok = newToken(token.SEMICOLON, lexer.ch)
@@ -58,7 +110,16 @@ func (lexer *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.STAR, lexer.ch)
 	case '/':
+		if lexer.peekChar() == '*' {
+			// skipWhitspace only leaves behind block comments that never end.
+			for lexer.ch != 0 {
+				lexer.readChar()
+			}
+			return token.Token{Type: token.ILLEGAL, Literal: "unterminated block comment"}
+		}
 		tok = newToken(token.SLASH, lexer.ch)
+	case '%':
+		tok = newToken(token.PERCENT, lexer.ch)
 	case '<':
 		tok = newToken(token.LESS, lexer.ch)
 	case '>':
@@ -72,6 +133,20 @@ func (lexer *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.ASSIGN, lexer.ch)
 		}
+	case '&':
+		if lexer.peekChar() == '&' {
+			lexer.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = newToken(token.ILLEGAL, lexer.ch)
+		}
+	case '|':
+		if lexer.peekChar() == '|' {
+			lexer.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else {
+			tok = newToken(token.ILLEGAL, lexer.ch)
+		}
 	case '!':
 		if lexer.peekChar() == '=' {
 			ch := lexer.ch
@@ -82,8 +157,19 @@ func (lexer *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, lexer.ch)
 		}
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = lexer.readString()
+		if lexer.atTripleQuote() {
+			literal, ok := lexer.readHeredoc()
+			if ok {
+				tok.Type = token.STRING
+				tok.Literal = literal
+			} else {
+				tok.Type = token.ILLEGAL
+				tok.Literal = "unterminated heredoc string"
+			}
+		} else {
+			tok.Type = token.STRING
+			tok.Literal = lexer.readString()
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -93,8 +179,7 @@ func (lexer *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(lexer.ch) {
-			tok.Literal = lexer.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = lexer.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lexer.ch)
@@ -114,13 +199,24 @@ func (lexer *Lexer) readIdentifier() string {
 	return lexer.input[position:lexer.position]
 }
 
-func (lexer *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when the digits are followed by a
+// `.` and more digits.
+func (lexer *Lexer) readNumber() (string, token.TokenType) {
 	position := lexer.position
 	for isDigit(lexer.ch) {
 		lexer.readChar()
 	}
 
-	return lexer.input[position:lexer.position]
+	if lexer.ch != '.' || !isDigit(lexer.peekChar()) {
+		return lexer.input[position:lexer.position], token.INT
+	}
+
+	lexer.readChar()
+	for isDigit(lexer.ch) {
+		lexer.readChar()
+	}
+
+	return lexer.input[position:lexer.position], token.FLOAT
 }
 
 func (lexer *Lexer) readString() string {
@@ -135,12 +231,96 @@ func (lexer *Lexer) readString() string {
 	return lexer.input[position:lexer.position]
 }
 
+// readHeredoc reads a triple-quoted string, which may span several lines. It
+// reports false when the input ends before the closing quotes.
+func (lexer *Lexer) readHeredoc() (string, bool) {
+	lexer.readChar()
+	lexer.readChar()
+
+	position := lexer.position + 1
+	for {
+		lexer.readChar()
+		if lexer.ch == 0 {
+			return "", false
+		}
+
+		if lexer.atTripleQuote() {
+			break
+		}
+	}
+
+	literal := lexer.input[position:lexer.position]
+	lexer.readChar()
+	lexer.readChar()
+
+	return literal, true
+}
+
+func (lexer *Lexer) atTripleQuote() bool {
+	return lexer.ch == '"' && strings.HasPrefix(lexer.input[lexer.readPosition:], `""`)
+}
+
+// skipWhitspace also skips comments, which run from // to the end of the
+// line or from /* to the matching */, so the parser never sees them. A block
+// comment that is never closed is left for readToken to report.
 func (lexer *Lexer) skipWhitspace() {
-	for lexer.ch == ' ' || lexer.ch == '\t' || lexer.ch == '\n' || lexer.ch == '\r' {
+	for {
+		switch {
+		case isWhitespace(lexer.ch):
+			lexer.readChar()
+		case lexer.ch == '/' && lexer.peekChar() == '/':
+			lexer.skipComment()
+		case lexer.ch == '/' && lexer.peekChar() == '*':
+			end := lexer.blockCommentEnd()
+			if end < 0 {
+				return
+			}
+			for lexer.position < end {
+				lexer.readChar()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// blockCommentEnd returns the position just past the */ that closes the block
+// comment starting at the current char, counting nested /* */ pairs, or -1
+// when the input ends first.
+func (lexer *Lexer) blockCommentEnd() int {
+	depth := 0
+
+	for index := lexer.position; index+1 < len(lexer.input); {
+		switch lexer.input[index : index+2] {
+		case "/*":
+			depth++
+			index += 2
+		case "*/":
+			depth--
+			index += 2
+			if depth == 0 {
+				return index
+			}
+		default:
+			index++
+		}
+	}
+
+	return -1
+}
+
+func (lexer *Lexer) skipComment() {
+	for !lexer.atLineBreak() && lexer.ch != 0 {
 		lexer.readChar()
 	}
 }
 
+// atLineBreak reports whether the current char ends a line. Line breaks may be
+// written as \n, \r\n or a lone \r; in a \r\n pair only the \n counts.
+func (lexer *Lexer) atLineBreak() bool {
+	return lexer.ch == '\n' || lexer.ch == '\r' && lexer.peekChar() != '\n'
+}
+
 func (lexer *Lexer) peekChar() byte {
 	if lexer.readPosition >= len(lexer.input) {
 		return 0
@@ -157,6 +337,10 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
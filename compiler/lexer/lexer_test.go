@@ -14,7 +14,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *%5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -75,6 +75,7 @@ if (5 < 10) {
 		{token.MINUS, "-"},
 		{token.SLASH, "/"},
 		{token.STAR, "*"},
+		{token.PERCENT, "%"},
 		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		{token.INT, "5"},
@@ -138,3 +139,327 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestHeredocStrings(tester *testing.T) {
+	input := `let page = """<h1>
+  "Monkey"
+</h1>""";
+""""""
+"";
+let broken = """never closed
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "page"},
+		{token.ASSIGN, "="},
+		{token.STRING, "<h1>\n  \"Monkey\"\n</h1>"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, ""},
+		{token.STRING, ""},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "broken"},
+		{token.ASSIGN, "="},
+		{token.ILLEGAL, "unterminated heredoc string"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, testcase := range tests {
+		token := lexer.NextToken()
+
+		if token.Type != testcase.expectedType {
+			tester.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, testcase.expectedType, token.Type)
+		}
+
+		if token.Literal != testcase.expectedLiteral {
+			tester.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, testcase.expectedLiteral, token.Literal)
+		}
+	}
+}
+
+func TestFloatTokens(tester *testing.T) {
+	input := `3.14 + 10.0 * 2;
+1.x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.PLUS, "+"},
+		{token.FLOAT, "10.0"},
+		{token.STAR, "*"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, testcase := range tests {
+		token := lexer.NextToken()
+
+		if token.Type != testcase.expectedType {
+			tester.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, testcase.expectedType, token.Type)
+		}
+
+		if token.Literal != testcase.expectedLiteral {
+			tester.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, testcase.expectedLiteral, token.Literal)
+		}
+	}
+}
+
+func TestLogicalOperatorTokens(tester *testing.T) {
+	input := `a && b || c & d`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "d"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, testcase := range tests {
+		token := lexer.NextToken()
+
+		if token.Type != testcase.expectedType {
+			tester.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, testcase.expectedType, token.Type)
+		}
+
+		if token.Literal != testcase.expectedLiteral {
+			tester.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, testcase.expectedLiteral, token.Literal)
+		}
+	}
+}
+
+func TestComments(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"5 // comment\n + 5", []token.TokenType{token.INT, token.PLUS, token.INT, token.EOF}},
+		{"let x = 5; // set x", []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF}},
+		{"// on its own line\r\nx\n//\ny // a / b", []token.TokenType{token.IDENT, token.IDENT, token.EOF}},
+		{"10 / 2", []token.TokenType{token.INT, token.SLASH, token.INT, token.EOF}},
+	}
+
+	for _, testcase := range tests {
+		lexer := New(testcase.input)
+
+		for i, expected := range testcase.expected {
+			tok := lexer.NextToken()
+			if tok.Type != expected {
+				tester.Fatalf("%q: tokens[%d] - tokentype wrong. expected=%q, got=%q (%q)",
+					testcase.input, i, expected, tok.Type, tok.Literal)
+			}
+		}
+	}
+
+	lexer := New("// first\n// second\nx")
+	if tok := lexer.NextToken(); tok.Line != 3 || tok.Column != 1 {
+		tester.Errorf("token after comments has wrong position. got=%d:%d", tok.Line, tok.Column)
+	}
+}
+
+func TestBlockComments(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{
+			"1 /* single line */ + 2",
+			[]token.Token{{Type: token.INT, Literal: "1"}, {Type: token.PLUS, Literal: "+"}, {Type: token.INT, Literal: "2"}},
+		},
+		{
+			"let x = /* spans\nseveral\r\nlines */ 5;",
+			[]token.Token{
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.INT, Literal: "5", Line: 3, Column: 10},
+				{Type: token.SEMICOLON, Literal: ";"},
+			},
+		},
+		{
+			"/* outer /* inner */ still commented */ x",
+			[]token.Token{{Type: token.IDENT, Literal: "x"}},
+		},
+		{
+			"/**/ 4 /* a */ /* b */ // c",
+			[]token.Token{{Type: token.INT, Literal: "4"}},
+		},
+		{
+			"10 / 2 * 3",
+			[]token.Token{
+				{Type: token.INT, Literal: "10"},
+				{Type: token.SLASH, Literal: "/"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.STAR, Literal: "*"},
+				{Type: token.INT, Literal: "3"},
+			},
+		},
+		{
+			"x /* outer /* inner */ never closed",
+			[]token.Token{
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.ILLEGAL, Literal: "unterminated block comment", Line: 1, Column: 3},
+			},
+		},
+	}
+
+	for _, testcase := range tests {
+		lexer := New(testcase.input)
+
+		for i, expected := range append(testcase.expected, token.Token{Type: token.EOF}) {
+			tok := lexer.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				tester.Fatalf("%q: tokens[%d] wrong. expected=%s %q, got=%s %q",
+					testcase.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+
+			if expected.Line != 0 && (tok.Line != expected.Line || tok.Column != expected.Column) {
+				tester.Errorf("%q: tokens[%d] position wrong. expected=%d:%d, got=%d:%d",
+					testcase.input, i, expected.Line, expected.Column, tok.Line, tok.Column)
+			}
+		}
+	}
+}
+
+func TestTokenPositions(tester *testing.T) {
+	input := `let x = 5;
+  x + "ten"`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"ten", 2, 7},
+		{"", 2, 12},
+	}
+
+	lexer := New(input)
+
+	for i, testcase := range tests {
+		token := lexer.NextToken()
+
+		if token.Literal != testcase.expectedLiteral {
+			tester.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, testcase.expectedLiteral, token.Literal)
+		}
+
+		if token.Line != testcase.expectedLine || token.Column != testcase.expectedColumn {
+			tester.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i,
+				testcase.expectedLine, testcase.expectedColumn, token.Line, token.Column)
+		}
+	}
+}
+
+func TestLineBreaks(tester *testing.T) {
+	input := "let a = 1;\r\nlet b = \"x\r\ny\";\r\n\r\n\tb\rfn"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "a", 1, 5},
+		{token.ASSIGN, "=", 1, 7},
+		{token.INT, "1", 1, 9},
+		{token.SEMICOLON, ";", 1, 10},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "b", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.STRING, "x\r\ny", 2, 9},
+		{token.SEMICOLON, ";", 3, 3},
+		{token.IDENT, "b", 5, 2},
+		{token.FUNCTION, "fn", 6, 1},
+		{token.EOF, "", 6, 3},
+	}
+
+	lexer := New(input)
+
+	for i, testcase := range tests {
+		token := lexer.NextToken()
+
+		if token.Type != testcase.expectedType {
+			tester.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, testcase.expectedType, token.Type)
+		}
+
+		if token.Literal != testcase.expectedLiteral {
+			tester.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, testcase.expectedLiteral, token.Literal)
+		}
+
+		if token.Line != testcase.expectedLine || token.Column != testcase.expectedColumn {
+			tester.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i,
+				testcase.expectedLine, testcase.expectedColumn, token.Line, token.Column)
+		}
+	}
+}
+
+func TestPeekToken(tester *testing.T) {
+	input := `let five = 5;
+five + 1`
+
+	expected := []token.Token{}
+	for lexer := New(input); ; {
+		tok := lexer.NextToken()
+		expected = append(expected, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	lexer := New(input)
+
+	if first := lexer.PeekToken(); first != lexer.PeekToken() || first != expected[0] {
+		tester.Fatalf("PeekToken advanced or returned the wrong token. got=%+v", first)
+	}
+
+	if third := lexer.PeekTokenN(3); third != expected[2] {
+		tester.Fatalf("PeekTokenN(3) wrong. expected=%+v, got=%+v", expected[2], third)
+	}
+
+	if beyond := lexer.PeekTokenN(len(expected) + 2); beyond.Type != token.EOF {
+		tester.Fatalf("PeekTokenN past the end wrong. expected EOF, got=%+v", beyond)
+	}
+
+	for i, want := range expected {
+		if i%2 == 0 {
+			if peeked := lexer.PeekToken(); peeked != want {
+				tester.Fatalf("tokens[%d] - PeekToken wrong. expected=%+v, got=%+v", i, want, peeked)
+			}
+		}
+
+		if tok := lexer.NextToken(); tok != want {
+			tester.Fatalf("tokens[%d] - NextToken after peeking wrong. expected=%+v, got=%+v", i, want, tok)
+		}
+	}
+}
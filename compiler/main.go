@@ -1,13 +1,24 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"monkey/repl"
+	"monkey/runner"
 	"os"
 	"os/user"
 )
 
+var engine = flag.String("engine", "vm", "use 'vm' or 'eval'")
+var types = flag.Bool("types", false, "annotate results with their type")
+
 func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "test" {
+		os.Exit(runner.RunTests(os.Stdout, flag.Args()[1:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +26,5 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, repl.Options{Engine: *engine, Types: *types})
 }
@@ -1,7 +1,13 @@
 package object
 
 import (
+	"cmp"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var Builtins = []struct {
@@ -15,14 +21,12 @@ var Builtins = []struct {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			switch arg := args[0].(type) {
-			case *Array:
-				return &Integer{Value: int64(len(arg.Elements))}
-			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
-			default:
+			lengthy, ok := args[0].(Lengthy)
+			if !ok {
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
+
+			return &Integer{Value: int64(lengthy.Length())}
 		},
 		},
 	},
@@ -123,10 +127,1119 @@ var Builtins = []struct {
 		},
 		},
 	},
-}
+	{
+		"upper",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
 
-func newError(format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+			if args[0].Type() != STRING_OBJECT {
+				return newError("argument to `upper` must be STRING, got %s", args[0].Type())
+			}
+
+			str := args[0].(*String)
+			return &String{Value: strings.ToUpper(str.Value)}
+		},
+		},
+	},
+	{
+		"lower",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != STRING_OBJECT {
+				return newError("argument to `lower` must be STRING, got %s", args[0].Type())
+			}
+
+			str := args[0].(*String)
+			return &String{Value: strings.ToLower(str.Value)}
+		},
+		},
+	},
+	{
+		"partition",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJECT {
+				return newError("argument to `partition` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if err := checkCallback("partition", args[1], 1); err != nil {
+				return err
+			}
+
+			matching := []Object{}
+			nonMatching := []Object{}
+
+			for _, element := range args[0].(*Array).Elements {
+				result := call(args[1], element)
+				if result.Type() == ERROR_OBJECT {
+					return callbackError("partition", result)
+				}
+
+				if result.Truthy() {
+					matching = append(matching, element)
+				} else {
+					nonMatching = append(nonMatching, element)
+				}
+			}
+
+			return &Array{Elements: []Object{
+				&Array{Elements: matching},
+				&Array{Elements: nonMatching},
+			}}
+		},
+		},
+	},
+	{
+		"group_by",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJECT {
+				return newError("argument to `group_by` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if err := checkCallback("group_by", args[1], 1); err != nil {
+				return err
+			}
+
+			hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+
+			for _, element := range args[0].(*Array).Elements {
+				key := call(args[1], element)
+				if key.Type() == ERROR_OBJECT {
+					return callbackError("group_by", key)
+				}
+
+				hashKey, ok := key.(Hashable)
+				if !ok || IsMutableArray(key) {
+					return newError("unusable as hash key: %s", key.Type())
+				}
+
+				pair, ok := hash.Pairs[hashKey.HashKey()]
+				if !ok {
+					pair = HashPair{Key: key, Value: &Array{Elements: []Object{}}}
+				}
+
+				group := pair.Value.(*Array)
+				group.Elements = append(group.Elements, element)
+				hash.Set(hashKey.HashKey(), pair)
+			}
+
+			return hash
+		},
+		},
+	},
+	{
+		"string",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJECT {
+				return newError("argument to `string` must be ARRAY, got %s", args[0].Type())
+			}
+
+			var out strings.Builder
+			for _, element := range args[0].(*Array).Elements {
+				integer, ok := element.(*Integer)
+				if !ok {
+					return newError("elements of `string` argument must be INTEGER, got %s", element.Type())
+				}
+
+				if integer.Value > unicode.MaxRune || !utf8.ValidRune(rune(integer.Value)) {
+					return newError("invalid code point %d in argument to `string`", integer.Value)
+				}
+
+				out.WriteRune(rune(integer.Value))
+			}
+
+			return &String{Value: out.String()}
+		},
+		},
+	},
+	{
+		"take",
+		&Builtin{Fn: func(args ...Object) Object {
+			array, count, err := sliceArguments("take", args)
+			if err != nil {
+				return err
+			}
+
+			newElements := make([]Object, count)
+			copy(newElements, array.Elements[:count])
+
+			return &Array{Elements: newElements}
+		},
+		},
+	},
+	{
+		"drop",
+		&Builtin{Fn: func(args ...Object) Object {
+			array, count, err := sliceArguments("drop", args)
+			if err != nil {
+				return err
+			}
+
+			newElements := make([]Object, len(array.Elements)-count)
+			copy(newElements, array.Elements[count:])
+
+			return &Array{Elements: newElements}
+		},
+		},
+	},
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != HASH_OBJECT {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*Hash).OrderedPairs()
+			keys := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
+
+			return &Array{Elements: keys}
+		},
+		},
+	},
+	{
+		"debug",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return &String{Value: debugString(args[0])}
+		},
+		},
+	},
+	{
+		"find",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			index, err := findIndex("find", call, args)
+			if err != nil {
+				return err
+			}
+
+			if index < 0 {
+				return nil
+			}
+
+			return args[0].(*Array).Elements[index]
+		},
+		},
+	},
+	{
+		"find_index",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			index, err := findIndex("find_index", call, args)
+			if err != nil {
+				return err
+			}
+
+			return &Integer{Value: int64(index)}
+		},
+		},
+	},
+	{
+		"str",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*String); ok {
+				return str
+			}
+
+			return &String{Value: args[0].Inspect()}
+		},
+		},
+	},
+	{
+		"repeat",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			count, ok := args[1].(*Integer)
+			if !ok {
+				return newError("second argument to `repeat` must be INTEGER, got %s", args[1].Type())
+			}
+
+			if count.Value < 0 {
+				return newError("second argument to `repeat` must not be negative, got %d", count.Value)
+			}
+
+			if count.Value > MaxRepeat {
+				return newError("second argument to `repeat` must be at most %d, got %d", MaxRepeat, count.Value)
+			}
+
+			// Arrays and hashes are copied so that the elements do not share
+			// state; every other value is immutable and can be shared.
+			elements := make([]Object, count.Value)
+			for i := range elements {
+				elements[i] = deepCopy(args[0])
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"matches",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, pattern, err := patternArguments("matches", args)
+			if err != nil {
+				return err
+			}
+
+			return NativeBoolToBooleanObject(pattern.MatchString(str))
+		},
+		},
+	},
+	{
+		"find_all",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, pattern, err := patternArguments("find_all", args)
+			if err != nil {
+				return err
+			}
+
+			matches := pattern.FindAllString(str, -1)
+			elements := make([]Object, len(matches))
+			for i, match := range matches {
+				elements[i] = &String{Value: match}
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"min_by",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			return selectBy("min_by", call, args, -1)
+		},
+		},
+	},
+	{
+		"max_by",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			return selectBy("max_by", call, args, 1)
+		},
+		},
+	},
+	{
+		"error",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			message, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `error` must be STRING, got %s", args[0].Type())
+			}
+
+			return &Error{Message: message.Value}
+		},
+		},
+	},
+	{
+		"is_error",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return NativeBoolToBooleanObject(args[0].Type() == ERROR_OBJECT)
+		},
+		},
+	},
+	{
+		"sprintf",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+
+			format, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `sprintf` must be STRING, got %s", args[0].Type())
+			}
+
+			values, err := formatValues(format.Value, args[1:])
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: fmt.Sprintf(format.Value, values...)}
+		},
+		},
+	},
+	{
+		"bool",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return NativeBoolToBooleanObject(args[0].Truthy())
+		},
+		},
+	},
+	{
+		"floordiv",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if !IsInteger(arg) {
+					return newError("arguments to `floordiv` must be INTEGER, got %s", arg.Type())
+				}
+			}
+
+			if IsZero(args[1]) {
+				return newError("division by zero")
+			}
+
+			// `/` truncates toward zero; step down when that rounded up.
+			quotient := IntegerOperation("/", args[0], args[1])
+			zero := &Integer{Value: 0}
+			if !IsZero(IntegerOperation("%", args[0], args[1])) &&
+				(CompareIntegers(args[0], zero) < 0) != (CompareIntegers(args[1], zero) < 0) {
+				quotient = IntegerOperation("-", quotient, &Integer{Value: 1})
+			}
+
+			return quotient
+		},
+		},
+	},
+	{
+		"zip_with",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			for _, arg := range args[:2] {
+				if arg.Type() != ARRAY_OBJECT {
+					return newError("arguments to `zip_with` must be ARRAY, got %s", arg.Type())
+				}
+			}
+
+			if err := checkCallback("zip_with", args[2], 2); err != nil {
+				return err
+			}
+
+			left := args[0].(*Array).Elements
+			right := args[1].(*Array).Elements
+			length := min(len(left), len(right))
+
+			results := make([]Object, length)
+			for i := 0; i < length; i++ {
+				result := call(args[2], left[i], right[i])
+				if result.Type() == ERROR_OBJECT {
+					return callbackError("zip_with", result)
+				}
+
+				results[i] = result
+			}
+
+			return &Array{Elements: results}
+		},
+		},
+	},
+	{
+		"unique",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJECT {
+				return newError("argument to `unique` must be ARRAY, got %s", args[0].Type())
+			}
+
+			unique := []Object{}
+			for _, element := range args[0].(*Array).Elements {
+				seen := slices.ContainsFunc(unique, func(kept Object) bool {
+					return Equals(kept, element)
+				})
+				if !seen {
+					unique = append(unique, element)
+				}
+			}
+
+			return &Array{Elements: unique}
+		},
+		},
+	},
+	{
+		"set",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJECT {
+				return newError("argument to `set` must be ARRAY, got %s", args[0].Type())
+			}
+
+			set := NewSet()
+			for _, element := range args[0].(*Array).Elements {
+				hashable, err := setElement(element)
+				if err != nil {
+					return err
+				}
+
+				set.Add(hashable)
+			}
+
+			return set
+		},
+		},
+	},
+	{
+		"set_add",
+		&Builtin{Fn: func(args ...Object) Object {
+			set, element, err := setArguments("set_add", args)
+			if err != nil {
+				return err
+			}
+
+			added := set.Copy()
+			added.Add(element)
+
+			return added
+		},
+		},
+	},
+	{
+		"set_has",
+		&Builtin{Fn: func(args ...Object) Object {
+			set, element, err := setArguments("set_has", args)
+			if err != nil {
+				return err
+			}
+
+			return NativeBoolToBooleanObject(set.Has(element))
+		},
+		},
+	},
+	{
+		"set_remove",
+		&Builtin{Fn: func(args ...Object) Object {
+			set, element, err := setArguments("set_remove", args)
+			if err != nil {
+				return err
+			}
+
+			removed := set.Copy()
+			removed.Remove(element)
+
+			return removed
+		},
+		},
+	},
+	{
+		"assert",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if args[0].Truthy() {
+				return TRUE
+			}
+
+			if len(args) == 1 {
+				return newFatalError("assertion failed")
+			}
+
+			message, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `assert` must be STRING, got %s", args[1].Type())
+			}
+
+			return newFatalError("assertion failed: %s", message.Value)
+		},
+		},
+	},
+	{
+		"chunk",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `chunk` must be ARRAY, got %s", args[0].Type())
+			}
+
+			size, ok := args[1].(*Integer)
+			if !ok {
+				return newError("second argument to `chunk` must be INTEGER, got %s", args[1].Type())
+			}
+
+			if size.Value <= 0 {
+				return newError("second argument to `chunk` must be positive, got %d", size.Value)
+			}
+
+			chunks := []Object{}
+			for start := 0; start < len(array.Elements); start += int(size.Value) {
+				end := min(start+int(size.Value), len(array.Elements))
+
+				elements := make([]Object, end-start)
+				copy(elements, array.Elements[start:end])
+
+				chunks = append(chunks, &Array{Elements: elements})
+			}
+
+			return &Array{Elements: chunks}
+		},
+		},
+	},
+	{
+		"scan",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `scan` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if err := checkCallback("scan", args[1], 2); err != nil {
+				return err
+			}
+
+			// There is no seed: the first element is the first accumulation,
+			// so the result is exactly as long as the input.
+			results := make([]Object, len(array.Elements))
+			for i, element := range array.Elements {
+				if i == 0 {
+					results[i] = element
+					continue
+				}
+
+				result := call(args[1], results[i-1], element)
+				if result.Type() == ERROR_OBJECT {
+					return callbackError("scan", result)
+				}
+
+				results[i] = result
+			}
+
+			return &Array{Elements: results}
+		},
+		},
+	},
+	{
+		"prefix_sum",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `prefix_sum` must be ARRAY, got %s", args[0].Type())
+			}
+
+			var sum Object = &Integer{Value: 0}
+
+			sums := make([]Object, len(array.Elements))
+			for i, element := range array.Elements {
+				if !IsInteger(element) {
+					return newError("elements of `prefix_sum` must be INTEGER, got %s", element.Type())
+				}
+
+				sum = IntegerOperation("+", sum, element)
+				sums[i] = sum
+			}
+
+			return &Array{Elements: sums}
+		},
+		},
+	},
+	{
+		"each",
+		&Builtin{HigherOrderFn: func(call Caller, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `each` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if err := checkCallback("each", args[1], 1); err != nil {
+				return err
+			}
+
+			for _, element := range array.Elements {
+				result := call(args[1], element)
+				if result.Type() == ERROR_OBJECT {
+					return callbackError("each", result)
+				}
+			}
+
+			return NULL
+		},
+		},
+	},
+	{
+		"freeze",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `freeze` must be ARRAY, got %s", args[0].Type())
+			}
+
+			return freeze(array)
+		},
+		},
+	},
+	{
+		// dump_bytecode only makes sense under the VM, so the evaluator
+		// leaves it out.
+		"dump_bytecode",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch fn := args[0].(type) {
+			case *Closure:
+				return &String{Value: fn.Fn.Instructions.String()}
+			case *CompiledFunction:
+				return &String{Value: fn.Instructions.String()}
+			default:
+				return newError("argument to `dump_bytecode` must be a compiled function, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"divmod",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			for _, arg := range args {
+				if !IsInteger(arg) {
+					return newError("arguments to `divmod` must be INTEGER, got %s", arg.Type())
+				}
+			}
+
+			if IsZero(args[1]) {
+				return newError("division by zero")
+			}
+
+			// Unlike floordiv, both halves truncate toward zero as `/` and `%` do.
+			return NewArray(
+				IntegerOperation("/", args[0], args[1]),
+				IntegerOperation("%", args[0], args[1]),
+			)
+		},
+		},
+	},
+}
+
+// freeze returns a frozen copy of array, freezing nested arrays as well. Every
+// element must be hashable so that the result can serve as a hash key.
+func freeze(array *Array) Object {
+	if array.Frozen {
+		return array
+	}
+
+	elements := make([]Object, len(array.Elements))
+	for i, element := range array.Elements {
+		if nested, ok := element.(*Array); ok {
+			frozen := freeze(nested)
+			if frozen.Type() == ERROR_OBJECT {
+				return frozen
+			}
+			element = frozen
+		}
+
+		if _, ok := element.(Hashable); !ok {
+			return newError("cannot freeze array containing %s", element.Type())
+		}
+
+		elements[i] = element
+	}
+
+	return &Array{Elements: elements, Frozen: true}
+}
+
+// MaxRepeat is the largest count `repeat` accepts, which keeps a mistyped
+// count from exhausting memory.
+const MaxRepeat = 1 << 24
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func newFatalError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Fatal: true}
+}
+
+// callbackError wraps cause, the error returned by the callback passed to the
+// builtin name, so that the error shows where it was raised from.
+func callbackError(name string, cause Object) *Error {
+	err := cause.(*Error)
+	return &Error{Message: fmt.Sprintf("callback to `%s` failed", name), Cause: err, Fatal: err.Fatal}
+}
+
+// sliceArguments validates the (array, count) arguments shared by builtins that
+// cut a prefix off an array, clamping count to the array's length.
+func sliceArguments(name string, args []Object) (*Array, int, *Error) {
+	if len(args) != 2 {
+		return nil, 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	array, ok := args[0].(*Array)
+	if !ok {
+		return nil, 0, newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	count, ok := args[1].(*Integer)
+	if !ok {
+		return nil, 0, newError("second argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+	}
+
+	if count.Value < 0 {
+		return nil, 0, newError("second argument to `%s` must not be negative, got %d", name, count.Value)
+	}
+
+	if count.Value > int64(len(array.Elements)) {
+		return array, len(array.Elements), nil
+	}
+
+	return array, int(count.Value), nil
+}
+
+// checkCallback verifies that fn can be called with the given number of
+// arguments by the builtin called name.
+func checkCallback(name string, fn Object, arguments int) *Error {
+	var parameters int
+
+	switch fn := fn.(type) {
+	case *Closure:
+		parameters = fn.Fn.NumParameters
+	case *Function:
+		parameters = len(fn.Parameters)
+	case *Builtin:
+		return nil
+	default:
+		return newError("callback to `%s` must be a function, got %s", name, fn.Type())
+	}
+
+	if parameters != arguments {
+		return newError("wrong number of callback arguments for `%s`: want=%d, got=%d",
+			name, arguments, parameters)
+	}
+
+	return nil
+}
+
+// findIndex validates the (array, predicate) arguments shared by find and
+// find_index and returns the index of the first element the predicate accepts,
+// or -1 when there is none. An error returned by the predicate is passed on.
+func findIndex(name string, call Caller, args []Object) (int, Object) {
+	if len(args) != 2 {
+		return -1, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if args[0].Type() != ARRAY_OBJECT {
+		return -1, newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	if err := checkCallback(name, args[1], 1); err != nil {
+		return -1, err
+	}
+
+	for index, element := range args[0].(*Array).Elements {
+		result := call(args[1], element)
+		if result.Type() == ERROR_OBJECT {
+			return -1, callbackError(name, result)
+		}
+
+		if result.Truthy() {
+			return index, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// selectBy returns the element of the array in args whose key, computed by the
+// callback, compares as direction (-1 for smallest, 1 for largest) against all
+// other keys. Ties keep the earliest element; an empty array yields nil.
+func selectBy(name string, call Caller, args []Object, direction int) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if args[0].Type() != ARRAY_OBJECT {
+		return newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	if err := checkCallback(name, args[1], 1); err != nil {
+		return err
+	}
+
+	var selected, selectedKey Object
+
+	for _, element := range args[0].(*Array).Elements {
+		key := call(args[1], element)
+		if key.Type() == ERROR_OBJECT {
+			return callbackError(name, key)
+		}
+
+		if key.Type() != INTEGER_OBJECT && key.Type() != STRING_OBJECT {
+			return newError("key returned by `%s` callback must be INTEGER or STRING, got %s",
+				name, key.Type())
+		}
+
+		if selected == nil {
+			selected, selectedKey = element, key
+			continue
+		}
+
+		if key.Type() != selectedKey.Type() {
+			return newError("keys returned by `%s` callback are not comparable: %s and %s",
+				name, selectedKey.Type(), key.Type())
+		}
+
+		if compareKeys(key, selectedKey) == direction {
+			selected, selectedKey = element, key
+		}
+	}
+
+	return selected
+}
+
+// compareKeys orders two integer or two string keys, returning -1, 0 or 1.
+func compareKeys(a, b Object) int {
+	switch a := a.(type) {
+	case *Integer:
+		return cmp.Compare(a.Value, b.(*Integer).Value)
+	case *String:
+		return cmp.Compare(a.Value, b.(*String).Value)
+	default:
+		return 0
+	}
+}
+
+// patternArguments validates the (string, pattern) arguments shared by the
+// regular expression builtins and compiles the pattern.
+func patternArguments(name string, args []Object) (string, *regexp.Regexp, *Error) {
+	if len(args) != 2 {
+		return "", nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	str, ok := args[0].(*String)
+	if !ok {
+		return "", nil, newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	source, ok := args[1].(*String)
+	if !ok {
+		return "", nil, newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	pattern, err := regexp.Compile(source.Value)
+	if err != nil {
+		return "", nil, newError("invalid pattern for `%s`: %s", name, err)
+	}
+
+	return str.Value, pattern, nil
+}
+
+// formatVerbs maps the verbs sprintf understands to the type of argument they
+// format.
+var formatVerbs = map[rune]ObjectType{
+	'd': INTEGER_OBJECT,
+	's': STRING_OBJECT,
+	't': BOOLEAN_OBJECT,
+}
+
+// formatValues checks args against the verbs in format and converts them to
+// the Go values fmt.Sprintf expects for those verbs.
+func formatValues(format string, args []Object) ([]interface{}, *Error) {
+	var values []interface{}
+
+	verbs := []rune(format)
+	for i := 0; i < len(verbs); i++ {
+		if verbs[i] != '%' {
+			continue
+		}
+
+		i++
+		if i == len(verbs) {
+			return nil, newError("format for `sprintf` ends with a lone %%")
+		}
+
+		if verbs[i] == '%' {
+			continue
+		}
+
+		want, ok := formatVerbs[verbs[i]]
+		if !ok {
+			return nil, newError("unsupported verb %%%c in `sprintf` format", verbs[i])
+		}
+
+		if len(values) == len(args) {
+			return nil, newError("missing argument for %%%c in `sprintf` format", verbs[i])
+		}
+
+		arg := args[len(values)]
+		if arg.Type() != want && !(want == INTEGER_OBJECT && IsInteger(arg)) {
+			return nil, newError("argument %d to `sprintf` must be %s for %%%c, got %s",
+				len(values)+1, want, verbs[i], arg.Type())
+		}
+
+		switch arg := arg.(type) {
+		case *Integer:
+			values = append(values, arg.Value)
+		case *BigInt:
+			values = append(values, arg.Value)
+		case *String:
+			values = append(values, arg.Value)
+		case *Boolean:
+			values = append(values, arg.Value)
+		}
+	}
+
+	if len(values) != len(args) {
+		return nil, newError("too many arguments for `sprintf` format: want=%d, got=%d",
+			len(values), len(args))
+	}
+
+	return values, nil
+}
+
+// setArguments validates the (set, element) arguments shared by the builtins
+// that work on one element of a set.
+func setArguments(name string, args []Object) (*Set, Hashable, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	set, ok := args[0].(*Set)
+	if !ok {
+		return nil, nil, newError("argument to `%s` must be SET, got %s", name, args[0].Type())
+	}
+
+	element, err := setElement(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return set, element, nil
+}
+
+func setElement(obj Object) (Hashable, *Error) {
+	hashable, ok := obj.(Hashable)
+	if !ok || IsMutableArray(obj) {
+		return nil, newError("unusable as set element: %s", obj.Type())
+	}
+
+	return hashable, nil
+}
+
+// deepCopy returns a copy of obj in which arrays and hashes, including nested
+// ones, are duplicated. Frozen arrays cannot change, so they and other values
+// are returned as they are.
+func deepCopy(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj
+		}
+
+		elements := make([]Object, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = deepCopy(element)
+		}
+		return &Array{Elements: elements}
+
+	case *Hash:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, len(obj.Pairs))}
+		for key, pair := range obj.Pairs {
+			hash.Pairs[key] = HashPair{Key: pair.Key, Value: deepCopy(pair.Value)}
+		}
+		hash.Order = append([]HashKey(nil), obj.Order...)
+		return hash
+
+	default:
+		return obj
+	}
+}
+
+// debugString describes the Go representation of obj, including details such as
+// element types and parameter counts that Inspect leaves out.
+func debugString(obj Object) string {
+	switch obj := obj.(type) {
+	case *Integer:
+		return fmt.Sprintf("%T{Value: %d}", obj, obj.Value)
+	case *Boolean:
+		return fmt.Sprintf("%T{Value: %t}", obj, obj.Value)
+	case *String:
+		return fmt.Sprintf("%T{Len: %d, Value: %q}", obj, len(obj.Value), obj.Value)
+	case *Array:
+		types := make([]string, len(obj.Elements))
+		for i, element := range obj.Elements {
+			types[i] = string(element.Type())
+		}
+		return fmt.Sprintf("%T{Len: %d, Elements: [%s]}", obj, len(obj.Elements), strings.Join(types, ", "))
+	case *Hash:
+		return fmt.Sprintf("%T{Len: %d}", obj, len(obj.Pairs))
+	case *Closure:
+		return fmt.Sprintf("%T{NumParameters: %d, NumLocals: %d, NumFree: %d}",
+			obj, obj.Fn.NumParameters, obj.Fn.NumLocals, len(obj.Free))
+	case *CompiledFunction:
+		return fmt.Sprintf("%T{NumParameters: %d, NumLocals: %d}", obj, obj.NumParameters, obj.NumLocals)
+	case *Function:
+		return fmt.Sprintf("%T{NumParameters: %d}", obj, len(obj.Parameters))
+	case *Error:
+		return fmt.Sprintf("%T{Message: %q}", obj, obj.Message)
+	default:
+		return fmt.Sprintf("%T{}", obj)
+	}
 }
 
 func GetBuiltinByName(name string) *Builtin {
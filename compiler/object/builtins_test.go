@@ -0,0 +1,113 @@
+package object
+
+import "testing"
+
+func TestRepeatCopiesCollections(tester *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array := &Array{Elements: []Object{inner}}
+
+	result := GetBuiltinByName("repeat").Fn(array, &Integer{Value: 2})
+
+	repeated, ok := result.(*Array)
+	if !ok {
+		tester.Fatalf("result is not Array. got=%T (%+v)", result, result)
+	}
+
+	first := repeated.Elements[0].(*Array)
+	second := repeated.Elements[1].(*Array)
+
+	if first == array || second == array || first == second {
+		tester.Errorf("repeated arrays share the original array")
+	}
+
+	if first.Elements[0] == inner || first.Elements[0] == second.Elements[0] {
+		tester.Errorf("nested arrays are shared between copies")
+	}
+
+	if first.Inspect() != "[[1]]" || second.Inspect() != "[[1]]" {
+		tester.Errorf("copies differ from the original. got=%s and %s", first.Inspect(), second.Inspect())
+	}
+
+	str := &String{Value: "a"}
+	result = GetBuiltinByName("repeat").Fn(str, &Integer{Value: 2})
+	if result.(*Array).Elements[0] != str {
+		tester.Errorf("immutable values should be shared")
+	}
+}
+
+func TestSetBuiltins(tester *testing.T) {
+	call := func(name string, args ...Object) Object {
+		return GetBuiltinByName(name).Fn(args...)
+	}
+	integers := func(values ...int64) *Array {
+		array := &Array{}
+		for _, value := range values {
+			array.Elements = append(array.Elements, &Integer{Value: value})
+		}
+		return array
+	}
+
+	set, ok := call("set", integers(3, 1, 3, 2, 1)).(*Set)
+	if !ok {
+		tester.Fatalf("set did not return a Set")
+	}
+
+	if set.Length() != 3 || set.Inspect() != "set([3, 1, 2])" {
+		tester.Errorf("set did not remove duplicates. got=%s", set.Inspect())
+	}
+
+	membership := []struct {
+		element  Object
+		expected *Boolean
+	}{
+		{&Integer{Value: 1}, TRUE},
+		{&Integer{Value: 4}, FALSE},
+		{&String{Value: "1"}, FALSE},
+	}
+
+	for _, testcase := range membership {
+		if call("set_has", set, testcase.element) != testcase.expected {
+			tester.Errorf("set_has(%s, %s) wrong. want=%s", set.Inspect(),
+				testcase.element.Inspect(), testcase.expected.Inspect())
+		}
+	}
+
+	added := call("set_add", set, &Integer{Value: 1}).(*Set)
+	added = call("set_add", added, &String{Value: "1"}).(*Set)
+	added = call("set_add", added, &String{Value: "1"}).(*Set)
+	if added.Inspect() != "set([3, 1, 2, 1])" || added.Length() != 4 {
+		tester.Errorf("set_add did not de-duplicate. got=%s", added.Inspect())
+	}
+
+	removed := call("set_remove", added, &Integer{Value: 1}).(*Set)
+	if removed.Inspect() != "set([3, 2, 1])" || call("set_has", removed, &Integer{Value: 1}) != FALSE {
+		tester.Errorf("set_remove did not remove the element. got=%s", removed.Inspect())
+	}
+
+	if set.Inspect() != "set([3, 1, 2])" {
+		tester.Errorf("builtins changed the original set. got=%s", set.Inspect())
+	}
+
+	errors := []struct {
+		result   Object
+		expected string
+	}{
+		{call("set", &Array{Elements: []Object{integers(1)}}), "unusable as set element: ARRAY"},
+		{call("set", &Integer{Value: 1}), "argument to `set` must be ARRAY, got INTEGER"},
+		{call("set_add", set, integers()), "unusable as set element: ARRAY"},
+		{call("set_has", integers(), &Integer{Value: 1}), "argument to `set_has` must be SET, got ARRAY"},
+		{call("set_remove", set), "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, testcase := range errors {
+		err, ok := testcase.result.(*Error)
+		if !ok {
+			tester.Errorf("result is not Error. got=%T (%+v)", testcase.result, testcase.result)
+			continue
+		}
+
+		if err.Message != testcase.expected {
+			tester.Errorf("wrong error message. want=%q, got=%q", testcase.expected, err.Message)
+		}
+	}
+}
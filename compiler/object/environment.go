@@ -3,6 +3,8 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	strict bool
 }
 
 func NewEnvironment() *Environment {
@@ -13,15 +15,32 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.strict = outer.strict
 	return env
 }
 
+// SetStrict toggles strict mode for env and the environments enclosed in it
+// from then on. In strict mode hash literals whose keys evaluate to the same
+// value are an error instead of keeping the last pair, matching the VM.
+func (env *Environment) SetStrict(strict bool) {
+	env.strict = strict
+}
+
+// Strict reports whether env is in strict mode.
+func (env *Environment) Strict() bool {
+	return env.strict
+}
+
+// Get looks name up in env and then in each enclosing environment in turn. The
+// chain is walked iteratively since deep recursion in Monkey code builds long ones.
 func (env *Environment) Get(name string) (Object, bool) {
-	object, ok := env.store[name]
-    if !ok && env.outer != nil {
-        object, ok = env.outer.Get(name)
-    }
-	return object, ok
+	for current := env; current != nil; current = current.outer {
+		if object, ok := current.store[name]; ok {
+			return object, true
+		}
+	}
+
+	return nil, false
 }
 
 func (env *Environment) Set(name string, value Object) Object {
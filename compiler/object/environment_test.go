@@ -0,0 +1,106 @@
+package object
+
+import "testing"
+
+func TestEnvironmentGetDeepChain(tester *testing.T) {
+	depth := 100000
+
+	global := NewEnvironment()
+	global.Set("answer", &Integer{Value: 42})
+
+	env := global
+	for i := 0; i < depth; i++ {
+		env = NewEnclosedEnvironment(env)
+	}
+	env.Set("local", &Integer{Value: 7})
+
+	tests := []struct {
+		name     string
+		expected int64
+	}{
+		{"answer", 42},
+		{"local", 7},
+	}
+
+	for _, testcase := range tests {
+		value, ok := env.Get(testcase.name)
+		if !ok {
+			tester.Fatalf("%q not found in environment chain", testcase.name)
+		}
+
+		integer, ok := value.(*Integer)
+		if !ok {
+			tester.Fatalf("%q is not Integer. got=%T (%+v)", testcase.name, value, value)
+		}
+
+		if integer.Value != testcase.expected {
+			tester.Errorf("%q has wrong value. got=%d, want=%d", testcase.name, integer.Value, testcase.expected)
+		}
+	}
+
+	if _, ok := env.Get("missing"); ok {
+		tester.Errorf("undefined name resolved in environment chain")
+	}
+
+	if _, ok := global.Get("local"); ok {
+		tester.Errorf("outer environment resolved a name from an inner one")
+	}
+}
+
+func TestEnvironmentSetAndGet(tester *testing.T) {
+	env := NewEnvironment()
+
+	value := &Integer{Value: 5}
+	if env.Set("x", value) != value {
+		tester.Errorf("Set did not return the value it stored")
+	}
+
+	got, ok := env.Get("x")
+	if !ok || got != value {
+		tester.Errorf("Get(%q) wrong. got=%v, %t", "x", got, ok)
+	}
+
+	if got, ok := env.Get("missing"); ok || got != nil {
+		tester.Errorf("Get(%q) found a value. got=%v", "missing", got)
+	}
+}
+
+func TestEnclosedEnvironmentShadowing(tester *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 10})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64
+	}{
+		{inner, "x", 10},
+		{inner, "y", 2},
+		{outer, "x", 1},
+	}
+
+	for _, testcase := range tests {
+		got, ok := testcase.env.Get(testcase.name)
+		if !ok {
+			tester.Fatalf("Get(%q) found nothing", testcase.name)
+		}
+
+		if got.(*Integer).Value != testcase.expected {
+			tester.Errorf("Get(%q) wrong. want=%d, got=%d", testcase.name,
+				testcase.expected, got.(*Integer).Value)
+		}
+	}
+
+	if _, ok := outer.Get("z"); ok {
+		tester.Errorf("outer environment sees bindings it never had")
+	}
+
+	inner.Set("z", &Integer{Value: 3})
+	if _, ok := outer.Get("z"); ok {
+		tester.Errorf("binding in the enclosed environment leaked to the outer one")
+	}
+}
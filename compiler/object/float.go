@@ -0,0 +1,103 @@
+package object
+
+import (
+	"cmp"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type Float struct {
+	Value float64
+}
+
+func (float *Float) Type() ObjectType { return FLOAT_OBJECT }
+func (float *Float) Truthy() bool     { return true }
+
+// Inspect prints the shortest decimal form that reads back as the same value,
+// keeping a ".0" on whole numbers so they are not mistaken for integers.
+func (float *Float) Inspect() string {
+	text := strconv.FormatFloat(float.Value, 'g', -1, 64)
+	if strings.ContainsAny(text, ".eIN") {
+		return text
+	}
+
+	return text + ".0"
+}
+
+// IsNumber reports whether obj is an integer or a Float.
+func IsNumber(obj Object) bool {
+	_, ok := obj.(*Float)
+	return ok || IsInteger(obj)
+}
+
+// IsZero reports whether the number obj is zero.
+func IsZero(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value == 0
+	case *Float:
+		return obj.Value == 0
+	default:
+		return false
+	}
+}
+
+// NumberOperation applies the arithmetic operator "+", "-", "*", "/" or "%" to
+// two numbers. Two integers give an integer as IntegerOperation does, with
+// division truncating; as soon as either operand is a Float the result is a
+// Float. Callers must rule out a zero divisor.
+func NumberOperation(operator string, left, right Object) Object {
+	if IsInteger(left) && IsInteger(right) {
+		return IntegerOperation(operator, left, right)
+	}
+
+	leftValue, rightValue := toFloat(left), toFloat(right)
+
+	switch operator {
+	case "+":
+		return &Float{Value: leftValue + rightValue}
+	case "-":
+		return &Float{Value: leftValue - rightValue}
+	case "*":
+		return &Float{Value: leftValue * rightValue}
+	case "%":
+		return &Float{Value: math.Mod(leftValue, rightValue)}
+	default:
+		return &Float{Value: leftValue / rightValue}
+	}
+}
+
+// CompareNumbers returns -1, 0 or +1 as the number left is less than, equal
+// to or greater than the number right.
+func CompareNumbers(left, right Object) int {
+	if IsInteger(left) && IsInteger(right) {
+		return CompareIntegers(left, right)
+	}
+
+	return cmp.Compare(toFloat(left), toFloat(right))
+}
+
+// NegateNumber returns the negation of the number operand.
+func NegateNumber(operand Object) Object {
+	if float, ok := operand.(*Float); ok {
+		return &Float{Value: -float.Value}
+	}
+
+	return NegateInteger(operand)
+}
+
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Float:
+		return obj.Value
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInt:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	default:
+		return 0
+	}
+}
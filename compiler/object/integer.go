@@ -0,0 +1,157 @@
+package object
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// BigInt is an integer outside the int64 range. Values that fit in an int64
+// are always Integers, so an Integer and a BigInt are never equal.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bigInt *BigInt) Type() ObjectType { return BIGINT_OBJECT }
+func (bigInt *BigInt) Truthy() bool     { return true }
+func (bigInt *BigInt) Inspect() string  { return bigInt.Value.String() }
+
+func (bigInt *BigInt) HashKey() HashKey {
+	hasher := fnv.New64a()
+	hasher.Write(bigInt.Value.Bytes())
+
+	value := hasher.Sum64()
+	if bigInt.Value.Sign() < 0 {
+		value = ^value
+	}
+
+	return HashKey{Type: bigInt.Type(), Value: value}
+}
+
+// IntegerFromBig returns value as an Integer when it fits in an int64 and as a
+// BigInt otherwise.
+func IntegerFromBig(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInt{Value: value}
+}
+
+// IsInteger reports whether obj is an Integer or a BigInt.
+func IsInteger(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	default:
+		return false
+	}
+}
+
+// IntegerOperation applies the arithmetic operator "+", "-", "*", "/" or "%"
+// to two integers. Integer results that overflow an int64 are promoted to
+// BigInt and BigInt results that fit are demoted to Integer. Division truncates
+// toward zero and the remainder takes the sign of the dividend; callers must
+// rule out a zero divisor.
+func IntegerOperation(operator string, left, right Object) Object {
+	leftInteger, leftOk := left.(*Integer)
+	rightInteger, rightOk := right.(*Integer)
+
+	if leftOk && rightOk {
+		result, ok := int64Operation(operator, leftInteger.Value, rightInteger.Value)
+		if ok {
+			return &Integer{Value: result}
+		}
+	}
+
+	leftValue, rightValue := bigValue(left), bigValue(right)
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(leftValue, rightValue)
+	case "-":
+		result.Sub(leftValue, rightValue)
+	case "*":
+		result.Mul(leftValue, rightValue)
+	case "/":
+		result.Quo(leftValue, rightValue)
+	case "%":
+		result.Rem(leftValue, rightValue)
+	}
+
+	return IntegerFromBig(result)
+}
+
+// CompareIntegers returns -1, 0 or +1 as the integer left is less than, equal
+// to or greater than the integer right.
+func CompareIntegers(left, right Object) int {
+	leftInteger, leftOk := left.(*Integer)
+	rightInteger, rightOk := right.(*Integer)
+
+	if leftOk && rightOk {
+		switch {
+		case leftInteger.Value < rightInteger.Value:
+			return -1
+		case leftInteger.Value > rightInteger.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return bigValue(left).Cmp(bigValue(right))
+}
+
+// NegateInteger returns the negation of the integer operand, promoting the
+// negation of the smallest int64.
+func NegateInteger(operand Object) Object {
+	integer, ok := operand.(*Integer)
+	if ok && integer.Value != math.MinInt64 {
+		return &Integer{Value: -integer.Value}
+	}
+
+	return IntegerFromBig(new(big.Int).Neg(bigValue(operand)))
+}
+
+// int64Operation applies operator to two int64s and reports false when the
+// result does not fit in an int64.
+func int64Operation(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		return result, (result > left) == (right > 0)
+	case "-":
+		result := left - right
+		return result, (result < left) == (right > 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return 0, true
+		}
+		if (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64) {
+			return 0, false
+		}
+		result := left * right
+		return result, result/right == left
+	case "/":
+		if left == math.MinInt64 && right == -1 {
+			return 0, false
+		}
+		return left / right, true
+	case "%":
+		return left % right, true
+	}
+
+	return 0, false
+}
+
+func bigValue(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
@@ -2,10 +2,14 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"maps"
 	"monkey/ast"
 	"monkey/code"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +17,8 @@ type ObjectType string
 
 const (
 	INTEGER_OBJECT        = "INTEGER"
+	BIGINT_OBJECT         = "BIGINT"
+	FLOAT_OBJECT          = "FLOAT"
 	BOOLEAN_OBJECT        = "BOOLEAN"
 	NULL_OBJECT           = "NULL"
 	RETURN_VALUE_OBJECT   = "RETURN_VALUE"
@@ -22,6 +28,7 @@ const (
 	BUILTIN_OBJECT        = "BUILTIN"
 	ARRAY_OBJECT          = "ARRAY"
 	HASH_OBJECT           = "HASH"
+	SET_OBJECT            = "SET"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	CLOSURE_OBJ           = "CLOSURE"
 )
@@ -29,29 +36,153 @@ const (
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	// Truthy reports how the object behaves as a condition: a boolean by its
+	// value, null as false and anything else, including 0 and "", as true.
+	Truthy() bool
+}
+
+// IsFunction reports whether obj is any kind of callable value. Functions
+// compare equal only to themselves and have no ordering.
+func IsFunction(obj Object) bool {
+	switch obj.(type) {
+	case *Function, *Builtin, *CompiledFunction, *Closure:
+		return true
+	default:
+		return false
+	}
+}
+
+// Equals reports whether a and b hold the same value. Arrays and hashes are
+// compared element by element; functions and other objects only equal
+// themselves.
+func Equals(a, b Object) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInt:
+		b, ok := b.(*BigInt)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		for i, element := range a.Elements {
+			if !Equals(element, b.Elements[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !Equals(pair.Value, other.Value) {
+				return false
+			}
+		}
+
+		return true
+	case *Set:
+		b, ok := b.(*Set)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		for key := range a.Elements {
+			if _, ok := b.Elements[key]; !ok {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
 }
 
 type Hashable interface {
 	HashKey() HashKey
 }
 
+// Lengthy is implemented by the objects `len` can measure.
+type Lengthy interface {
+	Length() int
+}
+
 type Integer struct {
 	Value int64
 }
 
 func (integer *Integer) Type() ObjectType { return INTEGER_OBJECT }
+func (integer *Integer) Truthy() bool     { return true }
 func (integer *Integer) Inspect() string  { return fmt.Sprintf("%d", integer.Value) }
 
+// NewInteger returns an Integer holding value.
+func NewInteger(value int64) *Integer {
+	return &Integer{Value: value}
+}
+
 type Boolean struct {
 	Value bool
 }
 
 func (boolean *Boolean) Type() ObjectType { return BOOLEAN_OBJECT }
+func (boolean *Boolean) Truthy() bool     { return boolean.Value }
 func (boolean *Boolean) Inspect() string  { return fmt.Sprintf("%t", boolean.Value) }
 
+// TRUE and FALSE are the only Boolean values the engines produce, so booleans
+// can be compared by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// NewBool returns the shared TRUE or FALSE for value, so booleans can be
+// compared by identity.
+func NewBool(value bool) *Boolean {
+	return NativeBoolToBooleanObject(value)
+}
+
+func NativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+
+	return FALSE
+}
+
 type Null struct{}
 
+// NULL is the only Null value the engines produce, so null can be compared by
+// identity like the booleans.
+var NULL = &Null{}
+
 func (null *Null) Type() ObjectType { return NULL_OBJECT }
+func (null *Null) Truthy() bool     { return false }
 func (null *Null) Inspect() string  { return "null" }
 
 type ReturnValue struct {
@@ -59,14 +190,29 @@ type ReturnValue struct {
 }
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJECT }
+func (rv *ReturnValue) Truthy() bool     { return rv.Value.Truthy() }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	Cause   *Error // the error that led to this one, if any
+	// Fatal errors, such as a failed assert, stop the program wherever they
+	// are raised instead of being returned as values.
+	Fatal bool
 }
 
 func (err *Error) Type() ObjectType { return ERROR_OBJECT }
-func (err *Error) Inspect() string  { return "ERROR: " + err.Message }
+func (err *Error) Truthy() bool     { return true }
+func (err *Error) Inspect() string  { return "ERROR: " + err.Chain() }
+
+// Chain renders the message followed by its causes, innermost last.
+func (err *Error) Chain() string {
+	if err.Cause == nil {
+		return err.Message
+	}
+
+	return fmt.Sprintf("%s (caused by: %s)", err.Message, err.Cause.Chain())
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
@@ -74,7 +220,11 @@ type Function struct {
 	Env        *Environment
 }
 
+// Arity returns the number of parameters fn must be called with.
+func (fn *Function) Arity() int { return len(fn.Parameters) }
+
 func (fn *Function) Type() ObjectType { return FUNCTION_OBJECT }
+func (fn *Function) Truthy() bool     { return true }
 func (fn *Function) Inspect() string {
 	var out bytes.Buffer
 
@@ -98,22 +248,55 @@ type String struct {
 }
 
 func (str *String) Type() ObjectType { return STRING_OBJECT }
+func (str *String) Truthy() bool     { return true }
 func (str *String) Inspect() string  { return str.Value }
 
+// NewString returns a String holding value.
+func NewString(value string) *String {
+	return &String{Value: value}
+}
+
+// Repr returns the string as a quoted literal with special characters
+// escaped, for echoing values back where Inspect's raw text would be ambiguous.
+func (str *String) Repr() string { return strconv.Quote(str.Value) }
+
+// Length returns the length of the string in bytes.
+func (str *String) Length() int { return len(str.Value) }
+
 type BuiltinFunction func(args ...Object) Object
 
+// Caller applies a Monkey function to arguments on behalf of a builtin. Each
+// engine supplies its own implementation when it invokes a HigherOrderFunction.
+type Caller func(fn Object, args ...Object) Object
+
+type HigherOrderFunction func(call Caller, args ...Object) Object
+
+// Builtin holds exactly one of Fn or HigherOrderFn. The latter is used by
+// builtins that need to call back into functions passed as arguments.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn            BuiltinFunction
+	HigherOrderFn HigherOrderFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJECT }
+func (b *Builtin) Truthy() bool     { return true }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
 type Array struct {
 	Elements []Object
+	// Frozen arrays come from the freeze builtin and never change, which is
+	// what makes them usable as hash keys.
+	Frozen bool
+}
+
+// NewArray returns an Array of elements. The array keeps its own copy, so the
+// caller may reuse a slice passed with `...`.
+func NewArray(elements ...Object) *Array {
+	return &Array{Elements: append([]Object{}, elements...)}
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJECT }
+func (a *Array) Truthy() bool     { return true }
 func (a *Array) Inspect() string {
 	var out bytes.Buffer
 
@@ -129,6 +312,8 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+func (a *Array) Length() int { return len(a.Elements) }
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -157,6 +342,27 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: hasher.Sum64()}
 }
 
+// HashKey combines the hash keys of the elements, so equal frozen arrays share
+// a key. Only frozen arrays, whose elements are all hashable, may be hashed.
+func (a *Array) HashKey() HashKey {
+	hasher := fnv.New64a()
+
+	for _, element := range a.Elements {
+		key := element.(Hashable).HashKey()
+		hasher.Write([]byte(key.Type))
+		hasher.Write(binary.LittleEndian.AppendUint64(nil, key.Value))
+	}
+
+	return HashKey{Type: a.Type(), Value: hasher.Sum64()}
+}
+
+// IsMutableArray reports whether obj is an array that is not frozen. Such an
+// array cannot be a hash key: changing it would leave it under a stale key.
+func IsMutableArray(obj Object) bool {
+	array, ok := obj.(*Array)
+	return ok && !array.Frozen
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -164,14 +370,47 @@ type HashPair struct {
 
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// Order lists the keys of Pairs in insertion order. It is maintained by
+	// Set; hashes that fill Pairs directly have no defined order.
+	Order []HashKey
+}
+
+// Set stores pair under key, appending key to Order the first time it is seen.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Order = append(h.Order, key)
+	}
+
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Length() int { return len(h.Pairs) }
+
+// OrderedPairs returns the pairs of h in insertion order when it is known.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+
+	if len(h.Order) == len(h.Pairs) {
+		for _, key := range h.Order {
+			pairs = append(pairs, h.Pairs[key])
+		}
+		return pairs
+	}
+
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJECT }
+func (h *Hash) Truthy() bool     { return true }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -182,23 +421,98 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Set is an unordered collection of distinct hashable values. Elements are
+// kept in insertion order for display.
+type Set struct {
+	Elements map[HashKey]Object
+	Order    []HashKey
+}
+
+func NewSet() *Set {
+	return &Set{Elements: make(map[HashKey]Object)}
+}
+
+// Add stores element unless an equal element is already present.
+func (s *Set) Add(element Hashable) {
+	key := element.HashKey()
+	if _, ok := s.Elements[key]; ok {
+		return
+	}
+
+	s.Elements[key] = element.(Object)
+	s.Order = append(s.Order, key)
+}
+
+func (s *Set) Has(element Hashable) bool {
+	_, ok := s.Elements[element.HashKey()]
+	return ok
+}
+
+func (s *Set) Remove(element Hashable) {
+	key := element.HashKey()
+	if _, ok := s.Elements[key]; !ok {
+		return
+	}
+
+	delete(s.Elements, key)
+	s.Order = slices.DeleteFunc(s.Order, func(other HashKey) bool { return other == key })
+}
+
+// Copy returns a set with the same elements that can be changed independently.
+func (s *Set) Copy() *Set {
+	return &Set{Elements: maps.Clone(s.Elements), Order: slices.Clone(s.Order)}
+}
+
+func (s *Set) Length() int { return len(s.Elements) }
+
+func (s *Set) Type() ObjectType { return SET_OBJECT }
+func (s *Set) Truthy() bool     { return true }
+func (s *Set) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, key := range s.Order {
+		elements = append(elements, s.Elements[key].Inspect())
+	}
+
+	out.WriteString("set([")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	// LineInfo holds the source line of the instruction at each offset of
+	// Instructions, or nil when the compiler did not record lines.
+	LineInfo []int
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Truthy() bool     { return true }
 func (cf *CompiledFunction) Inspect() string {
 	return fmt.Sprintf("CompiledFunction[%p]", cf)
 }
 
+// Equal reports whether other has the same instructions, locals, parameters
+// and line info, in which case either can stand in for the other.
+func (cf *CompiledFunction) Equal(other *CompiledFunction) bool {
+	return bytes.Equal(cf.Instructions, other.Instructions) &&
+		cf.NumLocals == other.NumLocals &&
+		cf.NumParameters == other.NumParameters &&
+		slices.Equal(cf.LineInfo, other.LineInfo)
+}
+
 type Closure struct {
 	Fn   *CompiledFunction
 	Free []Object
 }
 
 func (cl *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (cl *Closure) Truthy() bool     { return true }
 func (cl *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", cl)
 }
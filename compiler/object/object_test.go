@@ -0,0 +1,351 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestTruthy(tester *testing.T) {
+	tests := []struct {
+		object   Object
+		expected bool
+	}{
+		{TRUE, true},
+		{FALSE, false},
+		{&Boolean{Value: false}, false},
+		{NULL, false},
+		{&Integer{Value: 0}, true},
+		{&Integer{Value: -1}, true},
+		{&BigInt{Value: big.NewInt(0)}, true},
+		{&Float{Value: 0}, true},
+		{&String{Value: ""}, true},
+		{&String{Value: "monkey"}, true},
+		{&Array{}, true},
+		{&Hash{Pairs: map[HashKey]HashPair{}}, true},
+		{NewSet(), true},
+		{&Function{}, true},
+		{&Builtin{}, true},
+		{&CompiledFunction{}, true},
+		{&Closure{}, true},
+		{&Error{Message: "boom"}, true},
+		{&ReturnValue{Value: FALSE}, false},
+		{&ReturnValue{Value: &Integer{Value: 0}}, true},
+	}
+
+	for _, testcase := range tests {
+		if truthy := testcase.object.Truthy(); truthy != testcase.expected {
+			tester.Errorf("%T: Truthy() wrong. want=%t, got=%t", testcase.object, testcase.expected, truthy)
+		}
+	}
+}
+
+func TestIntegerOperationPromotes(tester *testing.T) {
+	tests := []struct {
+		operator string
+		left     int64
+		right    int64
+		expected string
+	}{
+		{"+", math.MaxInt64, 1, "9223372036854775808"},
+		{"+", math.MaxInt64, -1, "9223372036854775806"},
+		{"-", math.MinInt64, 1, "-9223372036854775809"},
+		{"-", 0, math.MinInt64, "9223372036854775808"},
+		{"*", math.MaxInt64, 2, "18446744073709551614"},
+		{"*", -1, math.MinInt64, "9223372036854775808"},
+		{"*", math.MinInt64, -1, "9223372036854775808"},
+		{"*", 1 << 32, 1 << 31, "9223372036854775808"},
+		{"*", -(1 << 32), 1 << 31, "-9223372036854775808"},
+		{"/", math.MinInt64, -1, "9223372036854775808"},
+		{"/", -7, 2, "-3"},
+	}
+
+	for _, test := range tests {
+		result := IntegerOperation(test.operator, &Integer{Value: test.left}, &Integer{Value: test.right})
+		if result.Inspect() != test.expected {
+			tester.Errorf("%d %s %d: want=%s, got=%s", test.left, test.operator, test.right, test.expected, result.Inspect())
+		}
+
+		_, isBig := result.(*BigInt)
+		if fits := IntegerFromBig(bigValue(result)).Type() == INTEGER_OBJECT; isBig == fits {
+			tester.Errorf("%d %s %d: wrong representation %T", test.left, test.operator, test.right, result)
+		}
+	}
+}
+
+func TestCompiledFunctionEqual(tester *testing.T) {
+	newFunction := func() *CompiledFunction {
+		return &CompiledFunction{
+			Instructions: concat(
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpReturnValue),
+			),
+			NumLocals:     1,
+			NumParameters: 1,
+		}
+	}
+
+	differentInstructions := newFunction()
+	differentInstructions.Instructions = concat(
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpReturnValue),
+	)
+
+	differentLocals := newFunction()
+	differentLocals.NumLocals = 2
+
+	differentParameters := newFunction()
+	differentParameters.NumParameters = 0
+
+	differentLines := newFunction()
+	differentLines.LineInfo = []int{1, 1, 1}
+
+	tests := []struct {
+		name     string
+		other    *CompiledFunction
+		expected bool
+	}{
+		{"identical", newFunction(), true},
+		{"instructions", differentInstructions, false},
+		{"locals", differentLocals, false},
+		{"parameters", differentParameters, false},
+		{"line info", differentLines, false},
+	}
+
+	for _, testcase := range tests {
+		function := newFunction()
+
+		if function.Equal(testcase.other) != testcase.expected {
+			tester.Errorf("%s: Equal returned %t, want %t", testcase.name,
+				!testcase.expected, testcase.expected)
+		}
+
+		if testcase.other.Equal(function) != testcase.expected {
+			tester.Errorf("%s: Equal is not symmetric", testcase.name)
+		}
+	}
+}
+
+func TestLengthy(tester *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for i := int64(0); i < 3; i++ {
+		key := &Integer{Value: i}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	}
+
+	tests := []struct {
+		object   Object
+		expected int
+	}{
+		{&String{Value: "monkey"}, 6},
+		{&String{Value: ""}, 0},
+		{&Array{Elements: []Object{TRUE, FALSE}}, 2},
+		{&Array{}, 0},
+		{hash, 3},
+	}
+
+	for _, testcase := range tests {
+		lengthy, ok := testcase.object.(Lengthy)
+		if !ok {
+			tester.Fatalf("%T does not implement Lengthy", testcase.object)
+		}
+
+		if lengthy.Length() != testcase.expected {
+			tester.Errorf("wrong length for %s. want=%d, got=%d",
+				testcase.object.Inspect(), testcase.expected, lengthy.Length())
+		}
+	}
+
+	for _, object := range []Object{&Integer{Value: 1}, TRUE, NULL} {
+		if _, ok := object.(Lengthy); ok {
+			tester.Errorf("%T should not implement Lengthy", object)
+		}
+	}
+}
+
+func TestEquals(tester *testing.T) {
+	newHash := func(values ...int64) *Hash {
+		hash := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i, value := range values {
+			key := &Integer{Value: int64(i)}
+			hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: value}})
+		}
+		return hash
+	}
+	closure := &Closure{Fn: &CompiledFunction{}}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{TRUE, NativeBoolToBooleanObject(true), true},
+		{TRUE, FALSE, false},
+		{NULL, NULL, true},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "x"}}}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "x"}}}}},
+			true,
+		},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{}}, false},
+		{newHash(1, 2), newHash(1, 2), true},
+		{newHash(1, 2), newHash(1, 3), false},
+		{newHash(1), newHash(1, 2), false},
+		{closure, closure, true},
+		{closure, &Closure{Fn: closure.Fn}, false},
+	}
+
+	for i, testcase := range tests {
+		if Equals(testcase.a, testcase.b) != testcase.expected {
+			tester.Errorf("tests[%d]: Equals(%s, %s) wrong. want=%t", i,
+				testcase.a.Inspect(), testcase.b.Inspect(), testcase.expected)
+		}
+	}
+}
+
+func TestStringRepr(tester *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`monkey`, `"monkey"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"two\nlines", `"two\nlines"`},
+		{"tab\there", `"tab\there"`},
+		{`back\slash`, `"back\\slash"`},
+		{"", `""`},
+	}
+
+	for _, testcase := range tests {
+		str := &String{Value: testcase.value}
+
+		if str.Repr() != testcase.expected {
+			tester.Errorf("Repr() wrong. want=%s, got=%s", testcase.expected, str.Repr())
+		}
+
+		if str.Inspect() != testcase.value {
+			tester.Errorf("Inspect() is not raw. want=%q, got=%q", testcase.value, str.Inspect())
+		}
+	}
+}
+
+func TestErrorCauseInspect(tester *testing.T) {
+	inner := &Error{Message: "division by zero"}
+	outer := &Error{Message: "callback to `map` failed", Cause: inner}
+
+	tests := []struct {
+		err      *Error
+		expected string
+	}{
+		{inner, "ERROR: division by zero"},
+		{outer, "ERROR: callback to `map` failed (caused by: division by zero)"},
+		{
+			&Error{Message: "pipeline failed", Cause: outer},
+			"ERROR: pipeline failed (caused by: callback to `map` failed (caused by: division by zero))",
+		},
+	}
+
+	for _, test := range tests {
+		if actual := test.err.Inspect(); actual != test.expected {
+			tester.Errorf("wrong Inspect. want=%q, got=%q", test.expected, actual)
+		}
+	}
+}
+
+func TestConstructors(tester *testing.T) {
+	if NewBool(true) != TRUE || NewBool(false) != FALSE {
+		tester.Errorf("NewBool does not return the shared booleans")
+	}
+
+	if integer := NewInteger(42); integer.Value != 42 {
+		tester.Errorf("NewInteger(42) holds %d", integer.Value)
+	}
+
+	if str := NewString("monkey"); str.Value != "monkey" {
+		tester.Errorf("NewString(%q) holds %q", "monkey", str.Value)
+	}
+
+	elements := []Object{NewInteger(1), NewString("two"), TRUE}
+	array := NewArray(elements...)
+	elements[0] = NULL
+
+	if array.Inspect() != `[1, two, true]` {
+		tester.Errorf("NewArray wraps the wrong elements. got=%s", array.Inspect())
+	}
+
+	if empty := NewArray(); empty.Elements == nil || len(empty.Elements) != 0 {
+		tester.Errorf("NewArray() is not an empty array. got=%#v", empty.Elements)
+	}
+}
+
+func TestBooleanHashKey(tester *testing.T) {
+	if TRUE.HashKey() != TRUE.HashKey() || FALSE.HashKey() != FALSE.HashKey() {
+		tester.Errorf("boolean hash keys are not stable")
+	}
+
+	if TRUE.HashKey() != (&Boolean{Value: true}).HashKey() {
+		tester.Errorf("TRUE and a separate true Boolean have different hash keys")
+	}
+
+	if TRUE.HashKey() == FALSE.HashKey() {
+		tester.Errorf("TRUE and FALSE have the same hash key")
+	}
+
+	if TRUE.HashKey() == (&Integer{Value: 1}).HashKey() {
+		tester.Errorf("true and 1 have the same hash key")
+	}
+}
+
+func TestFloatInspect(tester *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{0.5, "0.5"},
+		{-3.25, "-3.25"},
+		{1e21, "1e+21"},
+	}
+
+	for _, testcase := range tests {
+		if inspected := (&Float{Value: testcase.value}).Inspect(); inspected != testcase.expected {
+			tester.Errorf("wrong Inspect for %g. want=%q, got=%q", testcase.value, testcase.expected, inspected)
+		}
+	}
+}
+
+func TestFunctionArityAndInspect(tester *testing.T) {
+	program := parser.New(lexer.New("fn(x, y) { x + y }")).ParseProgram()
+	literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+	fn := &Function{Parameters: literal.Parameters, Body: literal.Body, Env: NewEnvironment()}
+
+	if fn.Arity() != 2 {
+		tester.Errorf("wrong arity. want=2, got=%d", fn.Arity())
+	}
+
+	expected := "fn(x, y) {\n(x + y)\n}"
+	if fn.Inspect() != expected {
+		tester.Errorf("wrong Inspect. want=%q, got=%q", expected, fn.Inspect())
+	}
+
+	if arity := (&Function{Body: literal.Body}).Arity(); arity != 0 {
+		tester.Errorf("wrong arity without parameters. want=0, got=%d", arity)
+	}
+}
+
+func concat(instructions ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, instruction := range instructions {
+		out = append(out, instruction...)
+	}
+
+	return out
+}
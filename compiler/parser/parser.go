@@ -1,20 +1,34 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"unicode/utf8"
 )
 
+// Error is a parser error together with the token it was reported at.
+type Error struct {
+	Message string
+	Token   token.Token
+}
+
 type Parser struct {
-	lexer  *lexer.Lexer
-	errors []string
+	lexer          *lexer.Lexer
+	errors         []string
+	detailedErrors []Error
 
 	currentToken token.Token
 	peekToken    token.Token
 
+	// depth counts the parentheses, brackets and hash braces enclosing the
+	// current token within the innermost block. Statements only end at depth 0.
+	depth int
+
 	prefixParseFunctions map[token.TokenType]prefixParseFunction
 	infixParseFunctions  map[token.TokenType]infixParseFunction
 }
@@ -28,12 +42,18 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.prefixParseFunctions = make(map[token.TokenType]prefixParseFunction)
 	parser.registerPrefix(token.IDENT, parser.parseIdentifier)
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
 	parser.registerPrefix(token.FALSE, parser.parseBoolean)
 	parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
+	parser.registerPrefix(token.UNLESS, parser.parseUnlessExpression)
+	parser.registerPrefix(token.WHILE, parser.parseWhileExpression)
+	parser.registerPrefix(token.UNTIL, parser.parseUntilExpression)
+	parser.registerPrefix(token.FOREACH, parser.parseForeachExpression)
+	parser.registerPrefix(token.TRY, parser.parseTryExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
 	parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
@@ -43,6 +63,9 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.registerInfix(token.PLUS, parser.parseInfixExpression)
 	parser.registerInfix(token.MINUS, parser.parseInfixExpression)
 	parser.registerInfix(token.SLASH, parser.parseInfixExpression)
+	parser.registerInfix(token.PERCENT, parser.parseInfixExpression)
+	parser.registerInfix(token.AND, parser.parseInfixExpression)
+	parser.registerInfix(token.OR, parser.parseInfixExpression)
 	parser.registerInfix(token.STAR, parser.parseInfixExpression)
 	parser.registerInfix(token.EQUAL, parser.parseInfixExpression)
 	parser.registerInfix(token.NOTEQUAL, parser.parseInfixExpression)
@@ -60,11 +83,53 @@ func (parser *Parser) Errors() []string {
 	return parser.errors
 }
 
+func (parser *Parser) DetailedErrors() []Error {
+	return parser.detailedErrors
+}
+
+func (parser *Parser) addError(tok token.Token, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+
+	parser.errors = append(parser.errors, message)
+	parser.detailedErrors = append(parser.detailedErrors, Error{Message: message, Token: tok})
+}
+
 func (parser *Parser) peekError(t token.TokenType) {
-	message := fmt.Sprintf("expected next token to be %s, got %s instead",
+	if parser.peekToken.Type == token.ILLEGAL {
+		parser.illegalTokenError(parser.peekToken)
+		return
+	}
+
+	parser.addError(parser.peekToken, "expected next token to be %s, got %s instead",
 		t, parser.peekToken.Type)
+}
 
-	parser.errors = append(parser.errors, message)
+// illegalTokenError reports a token the lexer could not make sense of. Its
+// literal is either the offending character or a description of the problem.
+func (parser *Parser) illegalTokenError(tok token.Token) {
+	if utf8.RuneCountInString(tok.Literal) == 1 {
+		parser.addError(tok, "illegal character '%s' at line %d", tok.Literal, tok.Line)
+		return
+	}
+
+	parser.addError(tok, "%s at line %d", tok.Literal, tok.Line)
+}
+
+// peekStartsLine reports whether the peek token is on a later line than the
+// current one. A `(` or `[` there starts a new statement instead of calling or
+// indexing the expression before it, so those statements need no semicolon.
+// Other operators at the start of a line still continue the expression, and so
+// does everything nested inside parentheses, brackets or a hash literal, where
+// no statement can end.
+func (parser *Parser) peekStartsLine() bool {
+	return parser.depth == 0 && parser.peekToken.Line > parser.currentToken.Line
+}
+
+// nest marks the parser as inside a pair of delimiters until the returned
+// function is called.
+func (parser *Parser) nest() func() {
+	parser.depth++
+	return func() { parser.depth-- }
 }
 
 func (parser *Parser) nextToken() {
@@ -101,6 +166,10 @@ func (parser *Parser) parseStatement() ast.Statement {
 		return parser.parseLetStatement()
 	case token.RETURN:
 		return parser.parseReturnStatement()
+	case token.DEBUGGER:
+		return parser.parseDebuggerStatement()
+	case token.IMPORT:
+		return parser.parseImportStatement()
 	default:
 		return parser.parseExpressionStatement()
 	}
@@ -148,6 +217,32 @@ func (parser *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (parser *Parser) parseDebuggerStatement() *ast.DebuggerStatement {
+	statement := &ast.DebuggerStatement{Token: parser.currentToken}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseImportStatement() ast.Statement {
+	statement := &ast.ImportStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement.Path = parser.currentToken.Literal
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
 func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{Token: parser.currentToken}
 	statement.Expression = parser.parseExpression(LOWEST)
@@ -160,6 +255,11 @@ func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (parser *Parser) parseExpression(precedence int) ast.Expression {
+	if parser.currentTokenIs(token.ILLEGAL) {
+		parser.illegalTokenError(parser.currentToken)
+		return nil
+	}
+
 	prefix := parser.prefixParseFunctions[parser.currentToken.Type]
 	if prefix == nil {
 		parser.noPrefixParseFunctionError(parser.currentToken.Type)
@@ -168,6 +268,10 @@ func (parser *Parser) parseExpression(precedence int) ast.Expression {
 	leftExpression := prefix()
 
 	for !parser.peekTokenIs(token.SEMICOLON) && precedence < parser.peekPrecedence() {
+		if parser.peekStartsLine() && (parser.peekTokenIs(token.LPAREN) || parser.peekTokenIs(token.LBRACKET)) {
+			return leftExpression
+		}
+
 		infix := parser.infixParseFunctions[parser.peekToken.Type]
 		if infix == nil {
 			return leftExpression
@@ -185,9 +289,12 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: parser.currentToken}
 
 	value, err := strconv.ParseInt(parser.currentToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return parser.parseBigIntegerLiteral()
+	}
+
 	if err != nil {
-		message := fmt.Sprintf("could not parse %q as integer", parser.currentToken.Literal)
-		parser.errors = append(parser.errors, message)
+		parser.addError(parser.currentToken, "could not parse %q as integer", parser.currentToken.Literal)
 		return nil
 	}
 
@@ -195,6 +302,26 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (parser *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(parser.currentToken.Literal, 64)
+	if err != nil {
+		parser.addError(parser.currentToken, "could not parse %q as float", parser.currentToken.Literal)
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: parser.currentToken, Value: value}
+}
+
+func (parser *Parser) parseBigIntegerLiteral() ast.Expression {
+	value, ok := new(big.Int).SetString(parser.currentToken.Literal, 0)
+	if !ok {
+		parser.addError(parser.currentToken, "could not parse %q as integer", parser.currentToken.Literal)
+		return nil
+	}
+
+	return &ast.BigIntegerLiteral{Token: parser.currentToken, Value: value}
+}
+
 func (parser *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
 }
@@ -231,6 +358,14 @@ func (parser *Parser) parseBoolean() ast.Expression {
 }
 
 func (parser *Parser) parseGroupedExpression() ast.Expression {
+	defer parser.nest()()
+
+	if parser.peekTokenIs(token.RPAREN) {
+		parser.addError(parser.currentToken, "empty parentheses")
+		parser.nextToken()
+		return nil
+	}
+
 	parser.nextToken()
 
 	expression := parser.parseExpression(LOWEST)
@@ -245,12 +380,131 @@ func (parser *Parser) parseGroupedExpression() ast.Expression {
 func (parser *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: parser.currentToken}
 
+	condition, consequence := parser.parseConditionalBlock()
+	if consequence == nil {
+		return nil
+	}
+
+	expression.Condition = condition
+	expression.Consequence = consequence
+
+	return parser.parseElseBlock(expression)
+}
+
+// parseUnlessExpression desugars `unless (c) { ... }` into `if (!c) { ... }`.
+func (parser *Parser) parseUnlessExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: parser.currentToken}
+
+	condition, consequence := parser.parseConditionalBlock()
+	if consequence == nil {
+		return nil
+	}
+
+	expression.Condition = negate(expression.Token, condition)
+	expression.Consequence = consequence
+
+	return parser.parseElseBlock(expression)
+}
+
+func (parser *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: parser.currentToken}
+
+	condition, body := parser.parseConditionalBlock()
+	if body == nil {
+		return nil
+	}
+
+	expression.Condition = condition
+	expression.Body = body
+
+	return expression
+}
+
+// parseUntilExpression desugars `until (c) { ... }` into `while (!c) { ... }`.
+func (parser *Parser) parseUntilExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: parser.currentToken}
+
+	condition, body := parser.parseConditionalBlock()
+	if body == nil {
+		return nil
+	}
+
+	expression.Condition = negate(expression.Token, condition)
+	expression.Body = body
+
+	return expression
+}
+
+// parseForeachExpression parses `foreach (value in iterable) { ... }` and
+// `foreach (key, value in iterable) { ... }`.
+func (parser *Parser) parseForeachExpression() ast.Expression {
+	expression := &ast.ForeachExpression{Token: parser.currentToken}
+
 	if !parser.expectPeek(token.LPAREN) {
 		return nil
 	}
 
+	if !parser.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Value = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if parser.peekTokenIs(token.COMMA) {
+		parser.nextToken()
+
+		if !parser.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Key = expression.Value
+		expression.Value = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	}
+
+	if !parser.expectPeek(token.IN) {
+		return nil
+	}
+
 	parser.nextToken()
-	expression.Condition = parser.parseExpression(LOWEST)
+	leave := parser.nest()
+	expression.Iterable = parser.parseExpression(LOWEST)
+	leave()
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = parser.parseBlockStatement()
+
+	return expression
+}
+
+func (parser *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = parser.parseBlockStatement()
+
+	if !parser.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Parameter = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
 
 	if !parser.expectPeek(token.RPAREN) {
 		return nil
@@ -260,8 +514,41 @@ func (parser *Parser) parseIfExpression() ast.Expression {
 		return nil
 	}
 
-	expression.Consequence = parser.parseBlockStatement()
+	expression.Handler = parser.parseBlockStatement()
+
+	return expression
+}
+
+// parseConditionalBlock parses the `(condition) { ... }` shared by if, unless,
+// while and until. The block is nil when either part is malformed.
+func (parser *Parser) parseConditionalBlock() (ast.Expression, *ast.BlockStatement) {
+	if !parser.expectPeek(token.LPAREN) {
+		return nil, nil
+	}
+
+	parser.nextToken()
+	leave := parser.nest()
+	condition := parser.parseExpression(LOWEST)
+	leave()
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil, nil
+	}
+
+	return condition, parser.parseBlockStatement()
+}
+
+// negate wraps condition in a `!` prefix expression positioned at keyword.
+func negate(keyword token.Token, condition ast.Expression) ast.Expression {
+	bang := token.Token{Type: token.BANG, Literal: "!", Line: keyword.Line, Column: keyword.Column}
+	return &ast.PrefixExpression{Token: bang, Operator: "!", Right: condition}
+}
 
+func (parser *Parser) parseElseBlock(expression *ast.IfExpression) ast.Expression {
 	if parser.peekTokenIs(token.ELSE) {
 		parser.nextToken()
 
@@ -279,6 +566,12 @@ func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: parser.currentToken}
 	block.Statements = []ast.Statement{}
 
+	// Statements inside the block end at newlines again, however deeply the
+	// block itself is nested.
+	depth := parser.depth
+	parser.depth = 0
+	defer func() { parser.depth = depth }()
+
 	parser.nextToken()
 
 	for !parser.currentTokenIs(token.RBRACE) && !parser.currentTokenIs(token.EOF) {
@@ -339,11 +632,77 @@ func (parser *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: parser.currentToken, Function: function}
-	expression.Arguments = parser.parseExpressionList(token.RPAREN)
+	expression.Arguments = parser.parseCallArguments()
 	return expression
 }
 
+func (parser *Parser) parseCallArguments() []ast.Expression {
+	defer parser.nest()()
+
+	list := []ast.Expression{}
+
+	if parser.peekTokenIs(token.RPAREN) {
+		parser.nextToken()
+		return list
+	}
+
+	seenKeyword := false
+
+	for {
+		parser.nextToken()
+
+		start := parser.currentToken
+		argument := parser.parseCallArgument()
+		if argument == nil {
+			return nil
+		}
+
+		_, isKeyword := argument.(*ast.KeywordArgument)
+		if seenKeyword && !isKeyword {
+			parser.addError(start, "positional argument follows keyword argument")
+			return nil
+		}
+		seenKeyword = seenKeyword || isKeyword
+
+		list = append(list, argument)
+
+		if !parser.peekTokenIs(token.COMMA) {
+			break
+		}
+		parser.nextToken()
+	}
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return list
+}
+
+func (parser *Parser) parseCallArgument() ast.Expression {
+	if !parser.currentTokenIs(token.IDENT) || !parser.peekTokenIs(token.COLON) {
+		return parser.parseExpression(LOWEST)
+	}
+
+	argument := &ast.KeywordArgument{
+		Token: parser.currentToken,
+		Name:  &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal},
+	}
+
+	parser.nextToken()
+	parser.nextToken()
+
+	argument.Value = parser.parseExpression(LOWEST)
+	if argument.Value == nil {
+		return nil
+	}
+
+	return argument
+}
+
 func (parser *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer parser.nest()()
+
 	list := []ast.Expression{}
 
 	if parser.peekTokenIs(end) {
@@ -352,12 +711,21 @@ func (parser *Parser) parseExpressionList(end token.TokenType) []ast.Expression
 	}
 
 	parser.nextToken()
-	list = append(list, parser.parseExpression(LOWEST))
+	element := parser.parseExpression(LOWEST)
+	if element == nil {
+		return nil
+	}
+	list = append(list, element)
 
 	for parser.peekTokenIs(token.COMMA) {
 		parser.nextToken()
 		parser.nextToken()
-		list = append(list, parser.parseExpression(LOWEST))
+
+		element := parser.parseExpression(LOWEST)
+		if element == nil {
+			return nil
+		}
+		list = append(list, element)
 	}
 
 	if !parser.expectPeek(end) {
@@ -380,6 +748,8 @@ func (parser *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer parser.nest()()
+
 	expression := &ast.IndexExpression{Token: parser.currentToken, Left: left}
 
 	parser.nextToken()
@@ -393,6 +763,8 @@ func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 }
 
 func (parser *Parser) parseHashLiteral() ast.Expression {
+	defer parser.nest()()
+
 	hash := &ast.HashLiteral{Token: parser.currentToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
@@ -408,6 +780,7 @@ func (parser *Parser) parseHashLiteral() ast.Expression {
 		value := parser.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
 			return nil
@@ -442,6 +815,8 @@ func (parser *Parser) expectPeek(t token.TokenType) bool {
 const (
 	_ int = iota
 	LOWEST
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +, -
@@ -452,6 +827,8 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQUAL:    EQUALS,
 	token.NOTEQUAL: EQUALS,
 	token.LESS:     LESSGREATER,
@@ -460,6 +837,7 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.STAR:     PRODUCT,
 	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
@@ -486,6 +864,5 @@ func (parser *Parser) currentPrecedence() int {
 }
 
 func (parser *Parser) noPrefixParseFunctionError(t token.TokenType) {
-	message := fmt.Sprintf("no prefix parse function for %s found", t)
-	parser.errors = append(parser.errors, message)
+	parser.addError(parser.currentToken, "no prefix parse function for %s found", t)
 }
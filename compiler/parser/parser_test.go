@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"testing"
@@ -72,6 +73,116 @@ return 9944322;
 	}
 }
 
+func TestDebuggerStatements(tester *testing.T) {
+	input := `
+debugger;
+debugger
+let x = 1;
+`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	if len(program.Statements) != 3 {
+		tester.Fatalf("program.Statements does not contain 3 statements. got=%d",
+			len(program.Statements))
+	}
+
+	for _, statement := range program.Statements[:2] {
+		if _, ok := statement.(*ast.DebuggerStatement); !ok {
+			tester.Errorf("statement is not *ast.DebuggerStatement. got=%T", statement)
+		}
+	}
+
+	if program.String() != "debugger;debugger;let x = 1;" {
+		tester.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestImportStatements(tester *testing.T) {
+	input := `import "util.monkey"; import "other.monkey"`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	expected := []string{"util.monkey", "other.monkey"}
+	if len(program.Statements) != len(expected) {
+		tester.Fatalf("program.Statements does not contain %d statements. got=%d",
+			len(expected), len(program.Statements))
+	}
+
+	for i, statement := range program.Statements {
+		importStatement, ok := statement.(*ast.ImportStatement)
+		if !ok {
+			tester.Fatalf("statement is not *ast.ImportStatement. got=%T", statement)
+		}
+
+		if importStatement.Path != expected[i] {
+			tester.Errorf("wrong import path. want=%q, got=%q", expected[i], importStatement.Path)
+		}
+	}
+
+	if program.String() != `import "util.monkey";import "other.monkey";` {
+		tester.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	parser = New(lexer.New("import util;"))
+	parser.ParseProgram()
+	if len(parser.Errors()) == 0 {
+		tester.Errorf("expected a parser error for an unquoted import path")
+	}
+}
+
+func TestNewlineSeparatedStatements(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1\nlet b = 2", []string{"let a = 1;", "let b = 2;"}},
+		{"x\ny", []string{"x", "y"}},
+		{"let a = b\n[1, 2]", []string{"let a = b;", "[1, 2]"}},
+		{"foo()\n(1 + 2)", []string{"foo()", "(1 + 2)"}},
+		{"a[0]\n[1]", []string{"(a[0])", "[1]"}},
+		{"let f = fn(x) { x }\n(f)(1)", []string{"let f = fn<f>(x) x;", "f(1)"}},
+		{"foo(1,\n  2)", []string{"foo(1, 2)"}},
+		{"let total = 1 +\n  2", []string{"let total = (1 + 2);"}},
+		{"let total = 1\n  + 2", []string{"let total = (1 + 2);"}},
+		{"foo(1)[0]", []string{"(foo(1)[0])"}},
+		{"foo(bar\n(1))", []string{"foo(bar(1))"}},
+		{"foo(bar\n[0])", []string{"foo((bar[0]))"}},
+		{"[a\n[0]]", []string{"[(a[0])]"}},
+		{"[f\n(1), 2]", []string{"[f(1), 2]"}},
+		{"let x = (a\n[0])", []string{"let x = (a[0]);"}},
+		{"let x = (f\n(1))", []string{"let x = f(1);"}},
+		{"a[b\n[0]]", []string{"(a[(b[0])])"}},
+		{"{\"k\": f\n(1)}", []string{"{k:f(1)}"}},
+		{"if (f\n(1)) { 2 }", []string{"iff(1) 2"}},
+		{"foo(fn() { a\n(1) })", []string{"foo(fn() a1)"}},
+	}
+
+	for _, testcase := range tests {
+		parser := New(lexer.New(testcase.input))
+		program := parser.ParseProgram()
+		checkParserErrors(tester, parser)
+
+		if len(program.Statements) != len(testcase.expected) {
+			tester.Fatalf("wrong number of statements for %q. want=%d, got=%d (%q)",
+				testcase.input, len(testcase.expected), len(program.Statements), program.String())
+		}
+
+		for i, statement := range program.Statements {
+			if statement.String() != testcase.expected[i] {
+				tester.Errorf("statement %d of %q wrong. want=%q, got=%q",
+					i, testcase.input, testcase.expected[i], statement.String())
+			}
+		}
+	}
+}
+
 func TestIdentifierExpression(tester *testing.T) {
 	input := "foobar;"
 
@@ -251,6 +362,18 @@ func TestOperatorPrecedenceParsing(tester *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || !c",
+			"((a && b) || (!c))",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -346,6 +469,131 @@ func TestOperatorPrecedenceParsing(tester *testing.T) {
 	}
 }
 
+func TestIntegerLiteralErrors(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"09", `could not parse "09" as integer`},
+	}
+
+	for _, testcase := range tests {
+		lexer := lexer.New(testcase.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) == 0 {
+			tester.Fatalf("expected parser errors for %q, got none", testcase.input)
+		}
+
+		if errors[0] != testcase.expected {
+			tester.Errorf("wrong parser error for %q. want=%q, got=%q",
+				testcase.input, testcase.expected, errors[0])
+		}
+	}
+
+	lexer := lexer.New("9223372036854775807")
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	testIntegerLiteral(tester, statement.Expression, 9223372036854775807)
+}
+
+func TestBigIntegerLiteral(tester *testing.T) {
+	tests := []string{"9223372036854775808", "99999999999999999999"}
+
+	for _, input := range tests {
+		parser := New(lexer.New(input))
+		program := parser.ParseProgram()
+		checkParserErrors(tester, parser)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := statement.Expression.(*ast.BigIntegerLiteral)
+		if !ok {
+			tester.Fatalf("expression is not *ast.BigIntegerLiteral. got=%T", statement.Expression)
+		}
+
+		expected, _ := new(big.Int).SetString(input, 0)
+		if literal.Value.Cmp(expected) != 0 {
+			tester.Errorf("literal.Value not %s. got=%s", expected, literal.Value)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(tester *testing.T) {
+	parser := New(lexer.New("3.25;"))
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.FloatLiteral)
+	if !ok {
+		tester.Fatalf("expression is not *ast.FloatLiteral. got=%T", statement.Expression)
+	}
+
+	if literal.Value != 3.25 {
+		tester.Errorf("literal.Value not 3.25. got=%g", literal.Value)
+	}
+
+	if literal.String() != "3.25" {
+		tester.Errorf("literal.String() not %q. got=%q", "3.25", literal.String())
+	}
+}
+
+func TestEmptyParentheses(tester *testing.T) {
+	tests := []string{"();", "let x = ();", "1 + ()"}
+
+	for _, input := range tests {
+		lexer := lexer.New(input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			tester.Fatalf("wrong number of parser errors for %q. want=1, got=%d (%q)",
+				input, len(errors), errors)
+		}
+
+		if errors[0] != "empty parentheses" {
+			tester.Errorf("wrong parser error for %q. want=%q, got=%q",
+				input, "empty parentheses", errors[0])
+		}
+	}
+}
+
+func TestIllegalCharacters(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = @;", "illegal character '@' at line 1"},
+		{"let x = 1;\nlet y = 2 + #;", "illegal character '#' at line 2"},
+		{"let $ = 1;", "illegal character '$' at line 1"},
+		{"1;\n\n  ~", "illegal character '~' at line 3"},
+		{`let s = """never closed`, "unterminated heredoc string at line 1"},
+		{"let x = 1;\n/* never closed", "unterminated block comment at line 2"},
+	}
+
+	for _, testcase := range tests {
+		lexer := lexer.New(testcase.input)
+		parser := New(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) == 0 {
+			tester.Fatalf("expected parser errors for %q, got none", testcase.input)
+		}
+
+		if errors[0] != testcase.expected {
+			tester.Errorf("wrong parser error for %q. want=%q, got=%q",
+				testcase.input, testcase.expected, errors[0])
+		}
+	}
+}
+
 func TestIfExpression(tester *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -395,6 +643,191 @@ func TestIfExpression(tester *testing.T) {
 	}
 }
 
+func TestUnlessExpression(tester *testing.T) {
+	input := `unless (x < y) { x } else { y }`
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.IfExpression)
+	if !ok {
+		tester.Fatalf("statement.Expression is not ast.IfExpression. got=%T",
+			statement.Expression)
+	}
+
+	condition, ok := expression.Condition.(*ast.PrefixExpression)
+	if !ok || condition.Operator != "!" {
+		tester.Fatalf("condition is not a negation. got=%s", expression.Condition)
+	}
+
+	if !testInfixExpression(tester, condition.Right, "x", "<", "y") {
+		return
+	}
+
+	if expression.Alternative == nil {
+		tester.Fatalf("expression.Alternative was nil")
+	}
+
+	if program.String() != "if(!(x < y)) xelse y" {
+		tester.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestWhileAndUntilExpressions(tester *testing.T) {
+	tests := []struct {
+		input     string
+		condition string
+	}{
+		{`while (x < y) { x }`, "(x < y)"},
+		{`until (x < y) { x }`, "(!(x < y))"},
+	}
+
+	for _, testcase := range tests {
+		lexer := lexer.New(testcase.input)
+		parser := New(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(tester, parser)
+
+		if len(program.Statements) != 1 {
+			tester.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := statement.Expression.(*ast.WhileExpression)
+		if !ok {
+			tester.Fatalf("statement.Expression is not ast.WhileExpression. got=%T",
+				statement.Expression)
+		}
+
+		if expression.Condition.String() != testcase.condition {
+			tester.Errorf("wrong condition for %q. want=%q, got=%q",
+				testcase.input, testcase.condition, expression.Condition.String())
+		}
+
+		if len(expression.Body.Statements) != 1 {
+			tester.Fatalf("body is not 1 statement. got=%d", len(expression.Body.Statements))
+		}
+
+		body := expression.Body.Statements[0].(*ast.ExpressionStatement)
+		testIdentifier(tester, body.Expression, "x")
+	}
+}
+
+func TestForeachExpressions(tester *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		value    string
+		iterable string
+		output   string
+	}{
+		{`foreach (x in arr) { x }`, "", "x", "arr", "foreach(x in arr) x"},
+		{`foreach (k, v in {1: 2}) { v }`, "k", "v", "{1:2}", "foreach(k, v in {1:2}) v"},
+	}
+
+	for _, testcase := range tests {
+		parser := New(lexer.New(testcase.input))
+		program := parser.ParseProgram()
+		checkParserErrors(tester, parser)
+
+		if len(program.Statements) != 1 {
+			tester.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := statement.Expression.(*ast.ForeachExpression)
+		if !ok {
+			tester.Fatalf("statement.Expression is not ast.ForeachExpression. got=%T",
+				statement.Expression)
+		}
+
+		if testcase.key == "" {
+			if expression.Key != nil {
+				tester.Errorf("expression.Key is not nil. got=%s", expression.Key)
+			}
+		} else {
+			testIdentifier(tester, expression.Key, testcase.key)
+		}
+
+		testIdentifier(tester, expression.Value, testcase.value)
+
+		if expression.Iterable.String() != testcase.iterable {
+			tester.Errorf("wrong iterable. want=%q, got=%q", testcase.iterable, expression.Iterable)
+		}
+
+		if len(expression.Body.Statements) != 1 {
+			tester.Fatalf("body is not 1 statement. got=%d", len(expression.Body.Statements))
+		}
+
+		if program.String() != testcase.output {
+			tester.Errorf("program.String() wrong. want=%q, got=%q", testcase.output, program.String())
+		}
+	}
+
+	for _, input := range []string{`foreach (x arr) { x }`, `foreach (in arr) { }`, `foreach (a, b, c in arr) { }`} {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+		if len(parser.Errors()) == 0 {
+			tester.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestTryExpression(tester *testing.T) {
+	input := `try { 1 / x } catch (e) { e }`
+
+	parser := New(lexer.New(input))
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	if len(program.Statements) != 1 {
+		tester.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.TryExpression)
+	if !ok {
+		tester.Fatalf("statement.Expression is not ast.TryExpression. got=%T",
+			statement.Expression)
+	}
+
+	if len(expression.Body.Statements) != 1 {
+		tester.Fatalf("body is not 1 statement. got=%d", len(expression.Body.Statements))
+	}
+
+	body := expression.Body.Statements[0].(*ast.ExpressionStatement)
+	if !testInfixExpression(tester, body.Expression, 1, "/", "x") {
+		return
+	}
+
+	testIdentifier(tester, expression.Parameter, "e")
+
+	if len(expression.Handler.Statements) != 1 {
+		tester.Fatalf("handler is not 1 statement. got=%d", len(expression.Handler.Statements))
+	}
+
+	handler := expression.Handler.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(tester, handler.Expression, "e")
+
+	if program.String() != "try (1 / x) catch(e) e" {
+		tester.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	for _, input := range []string{`try { 1 }`, `try { 1 } catch { 2 }`, `try { 1 } catch (1) { 2 }`} {
+		parser := New(lexer.New(input))
+		parser.ParseProgram()
+		if len(parser.Errors()) == 0 {
+			tester.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(tester *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -510,6 +943,104 @@ func TestCallExpressionParsing(tester *testing.T) {
 	testInfixExpression(tester, expression.Arguments[2], 4, "+", 5)
 }
 
+func TestKeywordArgumentParsing(tester *testing.T) {
+	input := "add(1, y: 2 * 3, z: w);"
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := statement.Expression.(*ast.CallExpression)
+	if !ok {
+		tester.Fatalf("statement.Expression is not ast.CallExpression. got=%T",
+			statement.Expression)
+	}
+
+	if len(expression.Arguments) != 3 {
+		tester.Fatalf("wrong number of arguments. got=%d", len(expression.Arguments))
+	}
+
+	testLiteralExpression(tester, expression.Arguments[0], 1)
+
+	keyword, ok := expression.Arguments[1].(*ast.KeywordArgument)
+	if !ok {
+		tester.Fatalf("expression.Arguments[1] is not ast.KeywordArgument. got=%T",
+			expression.Arguments[1])
+	}
+	testIdentifier(tester, keyword.Name, "y")
+	testInfixExpression(tester, keyword.Value, 2, "*", 3)
+
+	keyword, ok = expression.Arguments[2].(*ast.KeywordArgument)
+	if !ok {
+		tester.Fatalf("expression.Arguments[2] is not ast.KeywordArgument. got=%T",
+			expression.Arguments[2])
+	}
+	testIdentifier(tester, keyword.Name, "z")
+	testIdentifier(tester, keyword.Value, "w")
+
+	if program.String() != "add(1, y: (2 * 3), z: w)" {
+		tester.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestPositionalArgumentAfterKeyword(tester *testing.T) {
+	lexer := lexer.New("add(x: 1, 2);")
+	parser := New(lexer)
+	parser.ParseProgram()
+
+	errors := parser.DetailedErrors()
+	if len(errors) == 0 {
+		tester.Fatalf("expected parser errors, got none")
+	}
+
+	expected := "positional argument follows keyword argument"
+	if errors[0].Message != expected {
+		tester.Errorf("wrong parser error. want=%q, got=%q", expected, errors[0].Message)
+	}
+
+	if errors[0].Token.Column != 11 {
+		tester.Errorf("wrong error column. want=11, got=%d", errors[0].Token.Column)
+	}
+}
+
+func TestCallExpressionWithMalformedArguments(tester *testing.T) {
+	input := "add(1, , 3);"
+
+	lexer := lexer.New(input)
+	parser := New(lexer)
+	program := parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) == 0 {
+		tester.Fatalf("expected parser errors, got none")
+	}
+
+	expectedError := "no prefix parse function for , found"
+	if errors[0] != expectedError {
+		tester.Errorf("wrong parser error. want=%q, got=%q", expectedError, errors[0])
+	}
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		tester.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	expression, ok := statement.Expression.(*ast.CallExpression)
+	if !ok {
+		tester.Fatalf("statement.Expression is not ast.CallExpression. got=%T",
+			statement.Expression)
+	}
+
+	for i, argument := range expression.Arguments {
+		if argument == nil {
+			tester.Errorf("expression.Arguments[%d] is nil", i)
+		}
+	}
+}
+
 func TestStringLiteralExpression(tester *testing.T) {
 	input := `"hello world";`
 
@@ -609,6 +1140,17 @@ func TestParsingHashLiteralsStringKeys(tester *testing.T) {
 
 		testIntegerLiteral(tester, value, expectedValue)
 	}
+
+	expectedKeys := []string{"one", "two", "three"}
+	if len(hash.Keys) != len(expectedKeys) {
+		tester.Fatalf("hash.Keys has wrong length. got=%d", len(hash.Keys))
+	}
+
+	for i, key := range hash.Keys {
+		if key.String() != expectedKeys[i] {
+			tester.Errorf("hash.Keys[%d] is not %q. got=%q", i, expectedKeys[i], key.String())
+		}
+	}
 }
 
 func TestParsingEmptyHashLiteral(tester *testing.T) {
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -25,17 +28,29 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+type Options struct {
+	Engine string // "vm" (the default) or "eval"
+	Types  bool   // annotate results with their type; toggled with :types
+}
 
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalsSize)
-	symbolTable := compiler.NewSymbolTable()
+type engine func(program *ast.Program) (object.Object, error)
 
-	for index, value := range object.Builtins {
-		symbolTable.DefineBuiltin(index, value.Name)
+func Start(in io.Reader, out io.Writer, opts Options) {
+	scanner := bufio.NewScanner(in)
+
+	var execute engine
+	switch opts.Engine {
+	case "", "vm":
+		execute = newVmEngine()
+	case "eval":
+		execute = newEvalEngine()
+	default:
+		fmt.Fprintf(out, "unknown engine %q, use 'vm' or 'eval'\n", opts.Engine)
+		return
 	}
 
+	types := opts.Types
+
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -44,20 +59,57 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if strings.TrimSpace(line) == ":types" {
+			types = !types
+			if types {
+				io.WriteString(out, "types on\n")
+			} else {
+				io.WriteString(out, "types off\n")
+			}
+			continue
+		}
+
 		lexer := lexer.New(line)
 		parser := parser.New(lexer)
 
 		program := parser.ParseProgram()
 		if len(parser.Errors()) != 0 {
-			printParserErrors(out, parser.Errors())
+			printParserErrors(out, line, parser.DetailedErrors())
+			continue
+		}
+
+		result, error := execute(program)
+		if error != nil {
+			fmt.Fprintf(out, "Whoops! %s\n", error)
 			continue
 		}
 
+		if result != nil {
+			if types {
+				fmt.Fprintf(out, "%s : %s", echo(result), result.Type())
+			} else {
+				io.WriteString(out, echo(result))
+			}
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+func newVmEngine() engine {
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+
+	for index, value := range object.Builtins {
+		symbolTable.DefineBuiltin(index, value.Name)
+	}
+
+	return func(program *ast.Program) (object.Object, error) {
 		compiler := compiler.NewWithState(symbolTable, constants)
+		compiler.SetFolding(true)
 		error := compiler.Compile(program)
 		if error != nil {
-			fmt.Fprintf(out, "Whoops! Compilation failed:\n %s\n", error)
-			continue
+			return nil, fmt.Errorf("Compilation failed:\n %s", error)
 		}
 
 		code := compiler.Bytecode()
@@ -66,21 +118,55 @@ func Start(in io.Reader, out io.Writer) {
 		machine := vm.NewWithGlobalsStore(code, globals)
 		error = machine.Run()
 		if error != nil {
-			fmt.Fprintf(out, "Whoops! Executing bytecode failed:\n %s\n", error)
-			continue
+			return nil, fmt.Errorf("Executing bytecode failed:\n %s", error)
 		}
 
-		lastPoppedItem := machine.LastPoppedStackElem()
-		io.WriteString(out, lastPoppedItem.Inspect())
-		io.WriteString(out, "\n")
+		return machine.LastPoppedStackElem(), nil
+	}
+}
+
+func newEvalEngine() engine {
+	environment := object.NewEnvironment()
+
+	return func(program *ast.Program) (object.Object, error) {
+		return evaluator.Eval(program, environment), nil
+	}
+}
+
+// echo renders a result for display, quoting strings so that they can be told
+// apart from other values.
+func echo(result object.Object) string {
+	if str, ok := result.(*object.String); ok {
+		return str.Repr()
 	}
+
+	return result.Inspect()
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, line string, errors []parser.Error) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, "  parser errors:\n")
-	for _, message := range errors {
-		io.WriteString(out, "\t"+message+"\n")
+	for _, error := range errors {
+		io.WriteString(out, "\t"+error.Message+"\n")
+		io.WriteString(out, "\t"+line+"\n")
+		io.WriteString(out, "\t"+caret(line, error.Token.Column)+"\n")
 	}
 }
+
+// caret returns a line with a ^ under the given 1-based column of line. Tabs
+// are kept so the caret stays aligned with the source when printed.
+func caret(line string, column int) string {
+	var out strings.Builder
+
+	for i := 0; i < column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	out.WriteByte('^')
+
+	return out.String()
+}
@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartEngines(tester *testing.T) {
+	input := "5 * 2 + 1\n\"mon\" + \"key\"\n"
+
+	outputs := map[string]string{}
+	for _, engine := range []string{"vm", "eval"} {
+		var out bytes.Buffer
+		Start(strings.NewReader(input), &out, Options{Engine: engine})
+		outputs[engine] = out.String()
+	}
+
+	if !strings.Contains(outputs["vm"], "11\n") {
+		tester.Errorf("vm output does not contain result. got=%q", outputs["vm"])
+	}
+
+	if outputs["vm"] != outputs["eval"] {
+		tester.Errorf("engines produced different output. vm=%q, eval=%q",
+			outputs["vm"], outputs["eval"])
+	}
+}
+
+func TestDivisionByZero(tester *testing.T) {
+	for _, engine := range []string{"vm", "eval"} {
+		var out bytes.Buffer
+		Start(strings.NewReader("5 / 0\n1 + 1\n"), &out, Options{Engine: engine})
+
+		if !strings.Contains(out.String(), "division by zero") {
+			tester.Errorf("%s output does not report the error. got=%q", engine, out.String())
+		}
+
+		if !strings.Contains(out.String(), "2\n") {
+			tester.Errorf("%s did not keep going after the error. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestParserErrorCaret(tester *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let = 5\n"), &out, Options{})
+
+	expected := "\texpected next token to be IDENT, got = instead\n" +
+		"\tlet = 5\n" +
+		"\t    ^\n"
+
+	if !strings.Contains(out.String(), expected) {
+		tester.Errorf("output does not point at the error. want to contain=%q, got=%q",
+			expected, out.String())
+	}
+}
+
+func TestTypeAnnotations(tester *testing.T) {
+	input := "42\n:types\n42\n[1, 2]\n\"mon\" + \"key\"\n:types\n\"plain\"\n"
+
+	for _, engine := range []string{"vm", "eval"} {
+		var out bytes.Buffer
+		Start(strings.NewReader(input), &out, Options{Engine: engine})
+
+		expected := PROMPT + "42\n" +
+			PROMPT + "types on\n" +
+			PROMPT + "42 : INTEGER\n" +
+			PROMPT + "[1, 2] : ARRAY\n" +
+			PROMPT + "\"monkey\" : STRING\n" +
+			PROMPT + "types off\n" +
+			PROMPT + "\"plain\"\n" +
+			PROMPT
+
+		if out.String() != expected {
+			tester.Errorf("wrong %s output. want=%q, got=%q", engine, expected, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader("true\n"), &out, Options{Types: true})
+
+	if !strings.Contains(out.String(), "true : BOOLEAN\n") {
+		tester.Errorf("Options.Types did not enable annotations. got=%q", out.String())
+	}
+}
+
+func TestStringResultsAreQuoted(tester *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(`"""say "hi" """`+"\n"+`[1, "two"]`+"\n"), &out, Options{})
+
+	expected := PROMPT + `"say \"hi\" "` + "\n" + PROMPT + "[1, two]\n" + PROMPT
+	if out.String() != expected {
+		tester.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
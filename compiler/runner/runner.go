@@ -0,0 +1,137 @@
+// Package runner runs Monkey scripts as tests. Every top-level statement of a
+// script is run in turn, and a statement fails when it cannot be compiled or
+// run, which includes a failed `assert` anywhere inside it, or when its value
+// is an error.
+package runner
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+)
+
+// RunTests runs each script in paths, reports every failure and a summary to
+// out, and returns the exit status for the command: 0 when all statements
+// passed and 1 otherwise.
+func RunTests(out io.Writer, paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(out, "no test files given")
+		return 1
+	}
+
+	passed, failed := 0, 0
+
+	for _, path := range paths {
+		failures := runScript(path)
+		for _, failure := range failures {
+			fmt.Fprintf(out, "FAIL %s\n", failure)
+		}
+
+		if len(failures) == 0 {
+			fmt.Fprintf(out, "ok   %s\n", path)
+			passed++
+		} else {
+			failed++
+		}
+	}
+
+	fmt.Fprintf(out, "%d passed, %d failed\n", passed, failed)
+
+	if failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// runScript runs the script at path and returns a description of each failed
+// statement. A script that cannot be read or parsed fails as a whole.
+func runScript(path string) []string {
+	source, error := os.ReadFile(path)
+	if error != nil {
+		return []string{fmt.Sprintf("%s: %s", path, error)}
+	}
+
+	parser := parser.New(lexer.New(string(source)))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) != 0 {
+		var failures []string
+		for _, error := range parser.DetailedErrors() {
+			failures = append(failures, fmt.Sprintf("%s:%d: %s", path, error.Token.Line, error.Message))
+		}
+		return failures
+	}
+
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+
+	for index, value := range object.Builtins {
+		symbolTable.DefineBuiltin(index, value.Name)
+	}
+
+	var failures []string
+
+	for _, statement := range program.Statements {
+		compiler := compiler.NewWithState(symbolTable, constants)
+		compiler.SetImportDir(filepath.Dir(path))
+
+		result, error := runStatement(compiler, statement, globals)
+		constants = compiler.Bytecode().Constants
+
+		if error == nil {
+			if errorObject, ok := result.(*object.Error); ok {
+				error = fmt.Errorf("%s", errorObject.Message)
+			}
+		}
+
+		if error != nil {
+			failures = append(failures, fmt.Sprintf("%s:%d: %s", path, ast.Line(statement), error))
+			bindNull(symbolTable, statement, globals)
+		}
+	}
+
+	return failures
+}
+
+// bindNull sets the globals that the failed statement defined but never
+// assigned to NULL, so later statements reading them fail instead of crashing
+// the VM.
+func bindNull(symbolTable *compiler.SymbolTable, statement ast.Statement, globals []object.Object) {
+	ast.Walk(statement, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			return false
+		}
+
+		if let, ok := node.(*ast.LetStatement); ok {
+			symbol, ok := symbolTable.Resolve(let.Name.Value)
+			if ok && symbol.Scope == compiler.GlobalScope && globals[symbol.Index] == nil {
+				globals[symbol.Index] = object.NULL
+			}
+		}
+
+		return true
+	})
+}
+
+func runStatement(compiler *compiler.Compiler, statement ast.Statement, globals []object.Object) (object.Object, error) {
+	error := compiler.CompileInto(statement)
+	if error != nil {
+		return nil, error
+	}
+
+	machine := vm.NewWithGlobalsStore(compiler.Bytecode(), globals)
+	error = machine.Run()
+	if error != nil {
+		return nil, error
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTests(tester *testing.T) {
+	directory := tester.TempDir()
+
+	passing := writeScript(tester, directory, "passing.monkey", `
+let add = fn(a, b) { a + b };
+assert(add(1, 2) == 3);
+assert(len([1, 2]) == 2, "len of a pair");
+`)
+
+	failing := writeScript(tester, directory, "failing.monkey", `
+let add = fn(a, b) { a + b };
+assert(add(1, 2) == 4, "add is broken");
+assert(true);
+error("explicit failure");
+-true;
+`)
+
+	nestedAssert := writeScript(tester, directory, "nested_assert.monkey", `
+let check = fn() { assert(1 == 2, "inner"); true };
+check();
+if (true) { assert(false, "in if"); 1 }
+`)
+
+	failedLet := writeScript(tester, directory, "failed_let.monkey", `
+let f = fn() { 1 / 0 };
+let b = f();
+b + 1;
+`)
+
+	tests := []struct {
+		paths    []string
+		status   int
+		expected []string
+	}{
+		{
+			[]string{passing},
+			0,
+			[]string{"ok   " + passing, "1 passed, 0 failed"},
+		},
+		{
+			[]string{passing, failing},
+			1,
+			[]string{
+				"ok   " + passing,
+				"FAIL " + failing + ":3: assertion failed: add is broken",
+				"FAIL " + failing + ":5: explicit failure",
+				"FAIL " + failing + ":6: unsupported type for negation: BOOLEAN",
+				"1 passed, 1 failed",
+			},
+		},
+		{
+			[]string{failedLet},
+			1,
+			[]string{
+				"FAIL " + failedLet + ":3: division by zero",
+				"FAIL " + failedLet + ":4: unsupported types for binary operation: NULL INTEGER",
+				"0 passed, 1 failed",
+			},
+		},
+		{
+			[]string{nestedAssert},
+			1,
+			[]string{
+				"FAIL " + nestedAssert + ":3: assertion failed: inner",
+				"FAIL " + nestedAssert + ":4: assertion failed: in if",
+				"0 passed, 1 failed",
+			},
+		},
+		{
+			[]string{filepath.Join(directory, "missing.monkey")},
+			1,
+			[]string{"0 passed, 1 failed"},
+		},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		status := RunTests(&out, test.paths)
+
+		if status != test.status {
+			tester.Errorf("wrong exit status for %v. want=%d, got=%d", test.paths, test.status, status)
+		}
+
+		for _, line := range test.expected {
+			if !strings.Contains(out.String(), line+"\n") {
+				tester.Errorf("output does not contain %q. got=%q", line, out.String())
+			}
+		}
+	}
+}
+
+func writeScript(tester *testing.T, directory string, name string, source string) string {
+	path := filepath.Join(directory, name)
+
+	if error := os.WriteFile(path, []byte(source), 0o644); error != nil {
+		tester.Fatalf("could not write %s: %s", path, error)
+	}
+
+	return path
+}
@@ -5,6 +5,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the token's first character
+	Column  int // 1-based byte offset of the token's first character within its line
 }
 
 const (
@@ -14,21 +16,26 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
-	ASSIGN = "="
-	PLUS   = "+"
-	MINUS  = "-"
-	BANG   = "!"
-	STAR   = "*"
-	SLASH  = "/"
+	ASSIGN  = "="
+	PLUS    = "+"
+	MINUS   = "-"
+	BANG    = "!"
+	STAR    = "*"
+	SLASH   = "/"
+	PERCENT = "%"
 
 	LESS     = "<"
 	GREATER  = ">"
 	EQUAL    = "=="
 	NOTEQUAL = "!="
 
+	AND = "&&"
+	OR  = "||"
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -49,6 +56,15 @@ const (
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	DEBUGGER = "DEBUGGER"
+	UNLESS   = "UNLESS"
+	WHILE    = "WHILE"
+	UNTIL    = "UNTIL"
+	IMPORT   = "IMPORT"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FOREACH  = "FOREACH"
+	IN       = "IN"
 )
 
 var keywords = map[string]TokenType{
@@ -59,6 +75,16 @@ var keywords = map[string]TokenType{
 	"else":   ELSE,
 	"true":   TRUE,
 	"false":  FALSE,
+
+	"debugger": DEBUGGER,
+	"unless":   UNLESS,
+	"while":    WHILE,
+	"until":    UNTIL,
+	"import":   IMPORT,
+	"try":      TRY,
+	"catch":    CATCH,
+	"foreach":  FOREACH,
+	"in":       IN,
 }
 
 func LookupIdentifier(identifier string) TokenType {
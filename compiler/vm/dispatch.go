@@ -0,0 +1,271 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/object"
+)
+
+// Each op method executes the instruction whose opcode is at
+// instructionPointer and returns the position of its last operand byte, or the
+// position just before the next instruction to run for jumps. dispatch selects
+// the method with a switch, which benchmarks faster than a handler table.
+
+func (vm *VM) opConstant(instructions code.Instructions, instructionPointer int) (int, error) {
+	constantIndex := code.ReadUint16(instructions[instructionPointer+1:])
+
+	return instructionPointer + 2, vm.push(vm.constants[constantIndex])
+}
+
+func (vm *VM) opSmallInt(instructions code.Instructions, instructionPointer int) (int, error) {
+	value := code.ReadUint8(instructions[instructionPointer+1:])
+
+	return instructionPointer + 1, vm.push(&object.Integer{Value: int64(value)})
+}
+
+func (vm *VM) opNull(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.push(object.NULL)
+}
+
+func (vm *VM) opArray(instructions code.Instructions, instructionPointer int) (int, error) {
+	numberElements := int(code.ReadUint16(instructions[instructionPointer+1:]))
+
+	if numberElements > vm.stackPointer {
+		return 0, fmt.Errorf("stack underflow")
+	}
+
+	array := vm.buildArray(vm.stackPointer-numberElements, vm.stackPointer)
+	vm.stackPointer = vm.stackPointer - numberElements
+
+	return instructionPointer + 2, vm.push(array)
+}
+
+func (vm *VM) opHash(instructions code.Instructions, instructionPointer int) (int, error) {
+	numberElements := int(code.ReadUint16(instructions[instructionPointer+1:]))
+
+	if numberElements > vm.stackPointer {
+		return 0, fmt.Errorf("stack underflow")
+	}
+
+	hash, error := vm.buildHash(vm.stackPointer-numberElements, vm.stackPointer)
+	if error != nil {
+		return 0, error
+	}
+
+	vm.stackPointer = vm.stackPointer - numberElements
+
+	return instructionPointer + 2, vm.push(hash)
+}
+
+func (vm *VM) opIndex(instructions code.Instructions, instructionPointer int) (int, error) {
+	index, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	left, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	return instructionPointer, vm.executeIndexExpression(left, index)
+}
+
+func (vm *VM) opClosure(instructions code.Instructions, instructionPointer int) (int, error) {
+	constIndex := code.ReadUint16(instructions[instructionPointer+1:])
+	numFree := code.ReadUint8(instructions[instructionPointer+3:])
+
+	return instructionPointer + 3, vm.pushClosure(int(constIndex), int(numFree))
+}
+
+func (vm *VM) opCurrentClosure(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.push(vm.currentFrame().cl)
+}
+
+func (vm *VM) opBinary(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.executeBinaryOperation(code.Opcode(instructions[instructionPointer]))
+}
+
+func (vm *VM) opBang(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.executeBangOperator()
+}
+
+func (vm *VM) opMinus(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.executeMinusOperator()
+}
+
+func (vm *VM) opTrue(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.push(object.TRUE)
+}
+
+func (vm *VM) opFalse(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.push(object.FALSE)
+}
+
+func (vm *VM) opComparison(instructions code.Instructions, instructionPointer int) (int, error) {
+	return instructionPointer, vm.executeComparison(code.Opcode(instructions[instructionPointer]))
+}
+
+func (vm *VM) opJumpNotTrue(instructions code.Instructions, instructionPointer int) (int, error) {
+	position := int(code.ReadUint16(instructions[instructionPointer+1:]))
+
+	condition, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	if vm.strict && condition.Type() != object.BOOLEAN_OBJECT {
+		return 0, fmt.Errorf("non-boolean condition: %s", condition.Type())
+	}
+
+	if !condition.Truthy() {
+		return position - 1, nil
+	}
+
+	return instructionPointer + 2, nil
+}
+
+func (vm *VM) opJump(instructions code.Instructions, instructionPointer int) (int, error) {
+	position := int(code.ReadUint16(instructions[instructionPointer+1:]))
+
+	return position - 1, nil
+}
+
+func (vm *VM) opCall(instructions code.Instructions, instructionPointer int) (int, error) {
+	numArgs := code.ReadUint8(instructions[instructionPointer+1:])
+
+	return instructionPointer + 1, vm.executeCall(int(numArgs))
+}
+
+func (vm *VM) opReturnValue(instructions code.Instructions, instructionPointer int) (int, error) {
+	returnValue, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	frame := vm.popFrame()
+	vm.stackPointer = frame.basePointer - 1
+
+	return instructionPointer, vm.push(returnValue)
+}
+
+func (vm *VM) opReturn(instructions code.Instructions, instructionPointer int) (int, error) {
+	frame := vm.popFrame()
+	vm.stackPointer = frame.basePointer - 1
+
+	return instructionPointer, vm.push(object.NULL)
+}
+
+func (vm *VM) opSetGlobal(instructions code.Instructions, instructionPointer int) (int, error) {
+	globalIndex := code.ReadUint16(instructions[instructionPointer+1:])
+
+	value, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	vm.globals[globalIndex] = value
+
+	return instructionPointer + 2, nil
+}
+
+func (vm *VM) opGetGlobal(instructions code.Instructions, instructionPointer int) (int, error) {
+	globalIndex := code.ReadUint16(instructions[instructionPointer+1:])
+
+	return instructionPointer + 2, vm.push(vm.globals[globalIndex])
+}
+
+func (vm *VM) opGetLocal(instructions code.Instructions, instructionPointer int) (int, error) {
+	localIndex := code.ReadUint8(instructions[instructionPointer+1:])
+
+	slot, error := vm.localSlot(int(localIndex))
+	if error != nil {
+		return 0, error
+	}
+
+	return instructionPointer + 1, vm.push(vm.stack[slot])
+}
+
+func (vm *VM) opSetLocal(instructions code.Instructions, instructionPointer int) (int, error) {
+	localIndex := code.ReadUint8(instructions[instructionPointer+1:])
+
+	slot, error := vm.localSlot(int(localIndex))
+	if error != nil {
+		return 0, error
+	}
+
+	value, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	vm.stack[slot] = value
+
+	return instructionPointer + 1, nil
+}
+
+func (vm *VM) opGetBuiltin(instructions code.Instructions, instructionPointer int) (int, error) {
+	builtinIndex := code.ReadUint8(instructions[instructionPointer+1:])
+
+	if int(builtinIndex) >= len(object.Builtins) {
+		return 0, fmt.Errorf("unknown builtin index %d", builtinIndex)
+	}
+
+	definition := object.Builtins[builtinIndex]
+
+	return instructionPointer + 1, vm.push(definition.Builtin)
+}
+
+func (vm *VM) opGetFree(instructions code.Instructions, instructionPointer int) (int, error) {
+	freeIndex := code.ReadUint8(instructions[instructionPointer+1:])
+
+	currentClosure := vm.currentFrame().cl
+
+	return instructionPointer + 1, vm.push(currentClosure.Free[freeIndex])
+}
+
+func (vm *VM) opPop(instructions code.Instructions, instructionPointer int) (int, error) {
+	// A stray pop is harmless once there is nothing left to discard.
+	if vm.stackPointer == 0 && !vm.debug {
+		return instructionPointer, nil
+	}
+
+	_, error := vm.pop()
+
+	return instructionPointer, error
+}
+
+func (vm *VM) opBreak(instructions code.Instructions, instructionPointer int) (int, error) {
+	if vm.breakpointHandler != nil {
+		vm.breakpointHandler(vm.breakpoint(instructionPointer))
+	}
+
+	return instructionPointer, nil
+}
+
+func (vm *VM) opSetupCatch(instructions code.Instructions, instructionPointer int) (int, error) {
+	address := int(code.ReadUint16(instructions[instructionPointer+1:]))
+
+	vm.catches = append(vm.catches, catchHandler{
+		frameIndex:   vm.frameIndex,
+		stackPointer: vm.stackPointer,
+		address:      address,
+	})
+
+	return instructionPointer + 2, nil
+}
+
+func (vm *VM) opPopCatch(instructions code.Instructions, instructionPointer int) (int, error) {
+	vm.catches = vm.catches[:len(vm.catches)-1]
+
+	return instructionPointer, nil
+}
+
+func (vm *VM) opIterKeys(instructions code.Instructions, instructionPointer int) (int, error) {
+	iterable, error := vm.pop()
+	if error != nil {
+		return 0, error
+	}
+
+	return instructionPointer, vm.executeIterKeys(iterable)
+}
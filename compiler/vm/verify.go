@@ -0,0 +1,82 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/object"
+)
+
+// Verify checks the main program and every compiled function among the
+// constants for undefined opcodes, truncated operands, jump targets that do not
+// land on an instruction and loops that jump forward, so that bad bytecode is
+// rejected before Run instead of crashing it.
+func (vm *VM) Verify() error {
+	error := verifyInstructions(vm.frames[0].Instructions())
+	if error != nil {
+		return fmt.Errorf("main program: %s", error)
+	}
+
+	for index, constant := range vm.constants {
+		function, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		error := verifyInstructions(function.Instructions)
+		if error != nil {
+			return fmt.Errorf("function constant %d: %s", index, error)
+		}
+	}
+
+	return nil
+}
+
+func verifyInstructions(instructions code.Instructions) error {
+	type jump struct{ position, target int }
+
+	starts := map[int]bool{}
+	jumps := []jump{}
+
+	position := 0
+	for position < len(instructions) {
+		definition, error := code.Lookup(instructions[position])
+		if error != nil {
+			return fmt.Errorf("%s at %04d", error, position)
+		}
+
+		width := 0
+		for _, operandWidth := range definition.OperandWidths {
+			width += operandWidth
+		}
+
+		if position+1+width > len(instructions) {
+			return fmt.Errorf("truncated %s at %04d", definition.Name, position)
+		}
+
+		operands, _ := code.ReadOperands(definition, instructions[position+1:])
+
+		switch code.Opcode(instructions[position]) {
+		case code.OpJump, code.OpJumpNotTrue, code.OpSetupCatch:
+			jumps = append(jumps, jump{position, operands[0]})
+		case code.OpLoop:
+			if operands[0] > position {
+				return fmt.Errorf("forward loop target %d at %04d", operands[0], position)
+			}
+			jumps = append(jumps, jump{position, operands[0]})
+		}
+
+		starts[position] = true
+		position += 1 + width
+	}
+
+	// Jumping to the very end is how a program or function finishes.
+	starts[len(instructions)] = true
+
+	for _, jump := range jumps {
+		if !starts[jump.target] {
+			return fmt.Errorf("invalid jump target %d at %04d", jump.target, jump.position)
+		}
+	}
+
+	return nil
+}
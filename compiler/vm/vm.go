@@ -2,13 +2,20 @@ package vm
 
 import (
 	"fmt"
+	"io"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"strings"
 )
 
+// StackSize is the number of value slots. Every call holds a slot for the
+// callee and one for each argument and local until it returns, so deep
+// recursion usually runs out of stack before it reaches MaxFrames.
 const StackSize = 2048
 const GlobalsSize = 65535
+
+// MaxFrames limits how deeply calls can nest.
 const MaxFrames = 1024
 
 type VM struct {
@@ -20,14 +27,56 @@ type VM struct {
 
 	frames     []*Frame
 	frameIndex int
+
+	catches []catchHandler
+	// callbackFailed records that a callback run by the current builtin ended
+	// in a runtime error rather than returning a value.
+	callbackFailed bool
+
+	strict bool
+	debug  bool
+
+	breakpointHandler func(Breakpoint)
+
+	// trace receives a line for every executed instruction when set.
+	trace io.Writer
+}
+
+// Breakpoint is a snapshot of the VM taken when execution reaches a
+// `debugger` statement.
+type Breakpoint struct {
+	// Stack holds the values on the stack, bottom first.
+	Stack []object.Object
+	// Locals holds the parameters and local bindings of the current function.
+	Locals []object.Object
+	// Depth is the number of active frames, 1 in the main program.
+	Depth int
+	// InstructionPointer is the position of the OpBreak instruction within
+	// the current function's instructions.
+	InstructionPointer int
 }
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+// fatalError is the runtime error raised by a fatal *object.Error. No try
+// block catches it.
+type fatalError struct {
+	message string
+}
+
+func (err *fatalError) Error() string { return err.message }
+
+// catchHandler records where to resume when a runtime error occurs inside a
+// try block, along with the frame and stack depth to unwind to.
+type catchHandler struct {
+	frameIndex   int
+	stackPointer int
+	address      int
+}
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		LineInfo:     bytecode.LineInfo,
+	}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
@@ -53,237 +102,263 @@ func NewWithGlobalsStore(bytecode *compiler.Bytecode, store []object.Object) *VM
 	return vm
 }
 
-func (vm *VM) LastPoppedStackElem() object.Object {
-	return vm.stack[vm.stackPointer]
+// SetStrict toggles strict mode, in which conditions must evaluate to booleans
+// instead of following the lenient truthiness rules, and hash literals whose
+// keys evaluate to the same value are errors instead of keeping the last pair.
+func (vm *VM) SetStrict(strict bool) {
+	vm.strict = strict
 }
 
-func (vm *VM) Run() error {
-	var instructionPointer int
-	var instructions code.Instructions
-	var op code.Opcode
+// SetDebug toggles debug mode, in which bytecode inconsistencies the VM would
+// otherwise tolerate, such as an OpPop with nothing to pop, are errors.
+func (vm *VM) SetDebug(debug bool) {
+	vm.debug = debug
+}
 
-	for vm.currentFrame().instructionPointer < len(vm.currentFrame().Instructions())-1 {
-		vm.currentFrame().instructionPointer++
+// SetBreakpointHandler installs handler to be called whenever execution
+// reaches a `debugger` statement. Execution resumes once handler returns.
+// Without a handler `debugger` statements do nothing.
+func (vm *VM) SetBreakpointHandler(handler func(Breakpoint)) {
+	vm.breakpointHandler = handler
+}
 
-		instructionPointer = vm.currentFrame().instructionPointer
-		instructions = vm.currentFrame().Instructions()
-		op = code.Opcode(instructions[instructionPointer])
+// SetTrace makes the VM write every instruction it executes to w, along with
+// the value on top of the stack as the instruction starts. A nil w turns
+// tracing off again.
+func (vm *VM) SetTrace(w io.Writer) {
+	vm.trace = w
+}
 
-		switch op {
-		case code.OpConstant:
-			constantIndex := code.ReadUint16(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 2
+// LastPoppedStackElem returns the value most recently popped off the stack, or
+// object.NULL if nothing has been pushed yet, as for an empty program.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	obj := vm.stack[vm.stackPointer]
+	if obj == nil {
+		return object.NULL
+	}
 
-			error := vm.push(vm.constants[constantIndex])
-			if error != nil {
-				return error
-			}
+	return obj
+}
 
-		case code.OpSetGlobal:
-			globalIndex := code.ReadUint16(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 2
+// Run executes the program. When the bytecode carries line info, runtime
+// errors are prefixed with the source line of the failing instruction.
+func (vm *VM) Run() error {
+	error := vm.run(0)
+	if error != nil {
+		if line := vm.currentLine(); line > 0 {
+			return fmt.Errorf("line %d: %s", line, error)
+		}
+	}
 
-			vm.globals[globalIndex] = vm.pop()
+	return error
+}
 
-		case code.OpGetGlobal:
-			globalIndex := code.ReadUint16(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 2
+// currentLine returns the source line of the current instruction, or 0 when
+// it is unknown.
+func (vm *VM) currentLine() int {
+	frame := vm.currentFrame()
+	lines := frame.cl.Fn.LineInfo
 
-			error := vm.push(vm.globals[globalIndex])
-			if error != nil {
-				return error
-			}
+	if frame.instructionPointer < 0 || frame.instructionPointer >= len(lines) {
+		return 0
+	}
 
-		case code.OpSetLocal:
-			localIndex := code.ReadUint8(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 1
+	return lines[frame.instructionPointer]
+}
 
-			frame := vm.currentFrame()
+// run executes instructions until the current frame runs out of them or the
+// frame stack unwinds down to stopFrameIndex, which lets builtins call back into
+// closures without leaving the dispatch loop of the outer call. Errors raised
+// inside a try block started by this run resume execution at its handler.
+func (vm *VM) run(stopFrameIndex int) error {
+	for {
+		error := vm.dispatch(stopFrameIndex)
+		if error == nil {
+			return nil
+		}
 
-			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		if !vm.catch(error, stopFrameIndex) {
+			return error
+		}
+	}
+}
 
-		case code.OpGetLocal:
-			localIndex := code.ReadUint8(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 1
+// catch unwinds to the innermost try block and pushes the error message for
+// its handler. It reports false when no try block belongs to the current run
+// or the error is fatal.
+func (vm *VM) catch(err error, stopFrameIndex int) bool {
+	if _, fatal := err.(*fatalError); fatal || len(vm.catches) == 0 {
+		return false
+	}
 
-			frame := vm.currentFrame()
+	handler := vm.catches[len(vm.catches)-1]
+	if handler.frameIndex <= stopFrameIndex {
+		return false
+	}
 
-			error := vm.push(vm.stack[frame.basePointer+int(localIndex)])
-			if error != nil {
-				return error
-			}
+	vm.catches = vm.catches[:len(vm.catches)-1]
+	vm.frameIndex = handler.frameIndex
+	vm.stackPointer = handler.stackPointer
+	vm.currentFrame().instructionPointer = handler.address - 1
 
-		case code.OpGetBuiltin:
-			builtinIndex := code.ReadUint8(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 1
+	return vm.push(&object.String{Value: err.Error()}) == nil
+}
 
-			definition := object.Builtins[builtinIndex]
+// dispatch runs instructions through their op methods. Each method's result is
+// stored on the frame that was current when it started, so calls and returns
+// leave the caller positioned after the instruction.
+func (vm *VM) dispatch(stopFrameIndex int) error {
+	for vm.frameIndex > stopFrameIndex {
+		frame := vm.currentFrame()
+		instructions := frame.Instructions()
 
-			error := vm.push(definition.Builtin)
-			if error != nil {
-				return error
-			}
+		if frame.instructionPointer >= len(instructions)-1 {
+			break
+		}
 
-		case code.OpGetFree:
-			freeIndex := code.ReadUint8(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 1
+		frame.instructionPointer++
 
-			currentClosure := vm.currentFrame().cl
+		if vm.trace != nil {
+			vm.traceInstruction(instructions, frame.instructionPointer)
+		}
 
-			error := vm.push(currentClosure.Free[freeIndex])
-			if error != nil {
-				return error
-			}
+		var next int
+		var error error
 
+		switch code.Opcode(instructions[frame.instructionPointer]) {
+		case code.OpConstant:
+			next, error = vm.opConstant(instructions, frame.instructionPointer)
+		case code.OpSmallInt:
+			next, error = vm.opSmallInt(instructions, frame.instructionPointer)
+		case code.OpNull:
+			next, error = vm.opNull(instructions, frame.instructionPointer)
 		case code.OpArray:
-			numberElements := int(code.ReadUint16(instructions[instructionPointer+1:]))
-			vm.currentFrame().instructionPointer += 2
-
-			array := vm.buildArray(vm.stackPointer-numberElements, vm.stackPointer)
-			vm.stackPointer = vm.stackPointer - numberElements
-
-			error := vm.push(array)
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opArray(instructions, frame.instructionPointer)
 		case code.OpHash:
-			numberElements := int(code.ReadUint16(instructions[instructionPointer+1:]))
-			vm.currentFrame().instructionPointer += 2
-
-			hash, error := vm.buildHash(vm.stackPointer-numberElements, vm.stackPointer)
-			if error != nil {
-				return error
-			}
-
-			vm.stackPointer = vm.stackPointer - numberElements
-
-			error = vm.push(hash)
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opHash(instructions, frame.instructionPointer)
+		case code.OpIndex:
+			next, error = vm.opIndex(instructions, frame.instructionPointer)
 		case code.OpClosure:
-			constIndex := code.ReadUint16(instructions[instructionPointer+1:])
-			numFree := code.ReadUint8(instructions[instructionPointer+3:])
-			vm.currentFrame().instructionPointer += 3
-
-			error := vm.pushClosure(int(constIndex), int(numFree))
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opClosure(instructions, frame.instructionPointer)
 		case code.OpCurrentClosure:
-			currentClosure := vm.currentFrame().cl
-			error := vm.push(currentClosure)
-			if error != nil {
-				return error
-			}
-
-		case code.OpIndex:
-			index := vm.pop()
-			left := vm.pop()
-
-			error := vm.executeIndexExpression(left, index)
-			if error != nil {
-				return error
-			}
-
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
-			error := vm.executeBinaryOperation(op)
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opCurrentClosure(instructions, frame.instructionPointer)
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
+			next, error = vm.opBinary(instructions, frame.instructionPointer)
+		case code.OpBang:
+			next, error = vm.opBang(instructions, frame.instructionPointer)
+		case code.OpMinus:
+			next, error = vm.opMinus(instructions, frame.instructionPointer)
 		case code.OpTrue:
-			error := vm.push(True)
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opTrue(instructions, frame.instructionPointer)
 		case code.OpFalse:
-			error := vm.push(False)
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opFalse(instructions, frame.instructionPointer)
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+			next, error = vm.opComparison(instructions, frame.instructionPointer)
+		case code.OpJumpNotTrue:
+			next, error = vm.opJumpNotTrue(instructions, frame.instructionPointer)
+		// A loop back-edge jumps like any other; OpLoop only marks it as one.
+		case code.OpJump, code.OpLoop:
+			next, error = vm.opJump(instructions, frame.instructionPointer)
 		case code.OpCall:
-			numArgs := code.ReadUint8(instructions[instructionPointer+1:])
-			vm.currentFrame().instructionPointer += 1
-
-			error := vm.executeCall(int(numArgs))
-			if error != nil {
-				return error
-			}
-
+			next, error = vm.opCall(instructions, frame.instructionPointer)
 		case code.OpReturnValue:
-			returnValue := vm.pop()
+			next, error = vm.opReturnValue(instructions, frame.instructionPointer)
+		case code.OpReturn:
+			next, error = vm.opReturn(instructions, frame.instructionPointer)
+		case code.OpSetGlobal:
+			next, error = vm.opSetGlobal(instructions, frame.instructionPointer)
+		case code.OpGetGlobal:
+			next, error = vm.opGetGlobal(instructions, frame.instructionPointer)
+		case code.OpGetLocal:
+			next, error = vm.opGetLocal(instructions, frame.instructionPointer)
+		case code.OpSetLocal:
+			next, error = vm.opSetLocal(instructions, frame.instructionPointer)
+		case code.OpGetBuiltin:
+			next, error = vm.opGetBuiltin(instructions, frame.instructionPointer)
+		case code.OpGetFree:
+			next, error = vm.opGetFree(instructions, frame.instructionPointer)
+		case code.OpPop:
+			next, error = vm.opPop(instructions, frame.instructionPointer)
+		case code.OpBreak:
+			next, error = vm.opBreak(instructions, frame.instructionPointer)
+		case code.OpSetupCatch:
+			next, error = vm.opSetupCatch(instructions, frame.instructionPointer)
+		case code.OpPopCatch:
+			next, error = vm.opPopCatch(instructions, frame.instructionPointer)
+		case code.OpIterKeys:
+			next, error = vm.opIterKeys(instructions, frame.instructionPointer)
+		default:
+			return fmt.Errorf("unknown opcode %d", instructions[frame.instructionPointer])
+		}
 
-			frame := vm.popFrame()
-			vm.stackPointer = frame.basePointer - 1
+		if error != nil {
+			return error
+		}
 
-			error := vm.push(returnValue)
-			if error != nil {
-				return error
-			}
+		frame.instructionPointer = next
+	}
 
-		case code.OpReturn:
-			frame := vm.popFrame()
-			vm.stackPointer = frame.basePointer - 1
+	return nil
+}
 
-			error := vm.push(Null)
-			if error != nil {
-				return error
-			}
+func (vm *VM) traceInstruction(instructions code.Instructions, instructionPointer int) {
+	definition, error := code.Lookup(instructions[instructionPointer])
+	if error != nil {
+		fmt.Fprintf(vm.trace, "%04d %s\n", instructionPointer, error)
+		return
+	}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
-			error := vm.executeComparison(op)
-			if error != nil {
-				return error
-			}
+	operands, _ := code.ReadOperands(definition, instructions[instructionPointer+1:])
 
-		case code.OpBang:
-			error := vm.executeBangOperator()
-			if error != nil {
-				return error
-			}
+	var instruction strings.Builder
+	instruction.WriteString(definition.Name)
+	for _, operand := range operands {
+		fmt.Fprintf(&instruction, " %d", operand)
+	}
 
-		case code.OpMinus:
-			error := vm.executeMinusOperator()
-			if error != nil {
-				return error
-			}
+	top := "-"
+	if vm.stackPointer > 0 {
+		top = vm.stack[vm.stackPointer-1].Inspect()
+	}
 
-		case code.OpJump:
-			position := int(code.ReadUint16(instructions[instructionPointer+1:]))
-			vm.currentFrame().instructionPointer = position - 1
+	fmt.Fprintf(vm.trace, "%04d %-24s %s\n", instructionPointer, instruction.String(), top)
+}
 
-		case code.OpJumpNotTrue:
-			position := int(code.ReadUint16(instructions[instructionPointer+1:]))
-			vm.currentFrame().instructionPointer += 2
+func (vm *VM) breakpoint(instructionPointer int) Breakpoint {
+	frame := vm.currentFrame()
 
-			condition := vm.pop()
-			if !isTruthy(condition) {
-				vm.currentFrame().instructionPointer = position - 1
-			}
+	stack := make([]object.Object, vm.stackPointer)
+	copy(stack, vm.stack[:vm.stackPointer])
 
-		case code.OpNull:
-			error := vm.push(Null)
-			if error != nil {
-				return error
-			}
+	locals := make([]object.Object, frame.cl.Fn.NumLocals)
+	copy(locals, vm.stack[frame.basePointer:])
 
-		case code.OpPop:
-			vm.pop()
-		}
+	return Breakpoint{
+		Stack:              stack,
+		Locals:             locals,
+		Depth:              vm.frameIndex,
+		InstructionPointer: instructionPointer,
 	}
+}
 
-	return nil
+// localSlot returns the stack index of the current frame's local at localIndex.
+func (vm *VM) localSlot(localIndex int) (int, error) {
+	slot := vm.currentFrame().basePointer + localIndex
+	if slot < 0 || slot >= StackSize {
+		return 0, fmt.Errorf("local index %d out of range", localIndex)
+	}
+
+	return slot, nil
 }
 
 func (vm *VM) push(obj object.Object) error {
 	if vm.stackPointer >= StackSize {
-		return fmt.Errorf("stack overflow")
+		return fmt.Errorf("stack overflow (deep recursion?)")
+	}
+
+	// Return values only exist in the evaluator; the VM returns through frames.
+	if _, ok := obj.(*object.ReturnValue); ok {
+		return fmt.Errorf("internal error: %s on the value stack", object.RETURN_VALUE_OBJECT)
 	}
 
 	vm.stack[vm.stackPointer] = obj
@@ -292,49 +367,67 @@ func (vm *VM) push(obj object.Object) error {
 	return nil
 }
 
-func (vm *VM) pop() object.Object {
+func (vm *VM) pop() (object.Object, error) {
+	if vm.stackPointer <= 0 {
+		return nil, fmt.Errorf("stack underflow")
+	}
+
 	obj := vm.stack[vm.stackPointer-1]
 	vm.stackPointer--
-	return obj
+	return obj, nil
 }
 
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
-	right := vm.pop()
-	left := vm.pop()
+	right, error := vm.pop()
+	if error != nil {
+		return error
+	}
+
+	left, error := vm.pop()
+	if error != nil {
+		return error
+	}
 
 	leftType := left.Type()
 	rightType := right.Type()
 
 	switch {
-	case leftType == object.INTEGER_OBJECT && rightType == object.INTEGER_OBJECT:
-		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryNumberOperation(op, left, right)
 	case leftType == object.STRING_OBJECT && rightType == object.STRING_OBJECT:
 		return vm.executeBinaryStringOperation(op, left, right)
+	case op == code.OpAdd && (leftType == object.STRING_OBJECT) != (rightType == object.STRING_OBJECT):
+		return fmt.Errorf("cannot concatenate %s and %s (use str())", leftType, rightType)
 	default:
 		return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
 	}
 }
 
-func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
-	var result int64
+func (vm *VM) executeBinaryNumberOperation(op code.Opcode, left, right object.Object) error {
+	var operator string
 
 	switch op {
 	case code.OpAdd:
-		result = leftValue + rightValue
+		operator = "+"
 	case code.OpSub:
-		result = leftValue - rightValue
+		operator = "-"
 	case code.OpMul:
-		result = leftValue * rightValue
+		operator = "*"
 	case code.OpDiv:
-		result = leftValue / rightValue
+		if object.IsZero(right) {
+			return fmt.Errorf("division by zero")
+		}
+		operator = "/"
+	case code.OpMod:
+		if object.IsZero(right) {
+			return fmt.Errorf("division by zero")
+		}
+		operator = "%"
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return fmt.Errorf("unknown number operator: %d", op)
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	return vm.push(object.NumberOperation(operator, left, right))
 }
 
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
@@ -349,80 +442,91 @@ func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Ob
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
-	right := vm.pop()
-	left := vm.pop()
+	right, error := vm.pop()
+	if error != nil {
+		return error
+	}
+
+	left, error := vm.pop()
+	if error != nil {
+		return error
+	}
+
+	if isNumber(left) && isNumber(right) {
+		return vm.executeNumberComparison(op, left, right)
+	}
+
+	leftString, leftIsString := left.(*object.String)
+	rightString, rightIsString := right.(*object.String)
+	if leftIsString && rightIsString && op != code.OpGreaterThan {
+		// Literals are interned, so equal strings often share a pointer and
+		// their bytes need not be compared.
+		equal := leftString == rightString || leftString.Value == rightString.Value
+		if op == code.OpNotEqual {
+			equal = !equal
+		}
 
-	if left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT {
-		return vm.executeIntegerComparison(op, left, right)
+		return vm.push(object.NativeBoolToBooleanObject(equal))
 	}
 
+	// Everything else, functions included, is equal by identity only.
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(object.NativeBoolToBooleanObject(right == left))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(object.NativeBoolToBooleanObject(right != left))
 	default:
+		for _, operand := range []object.Object{left, right} {
+			if object.IsFunction(operand) {
+				return fmt.Errorf("unsupported operand type for comparison: %s", operand.Type())
+			}
+		}
+
 		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
 	}
 }
 
-func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+func (vm *VM) executeNumberComparison(op code.Opcode, left, right object.Object) error {
+	comparison := object.CompareNumbers(left, right)
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+		return vm.push(object.NativeBoolToBooleanObject(comparison == 0))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+		return vm.push(object.NativeBoolToBooleanObject(comparison != 0))
 	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+		return vm.push(object.NativeBoolToBooleanObject(comparison > 0))
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
 }
 
-func (vm *VM) executeBangOperator() error {
-	operand := vm.pop()
-
-	switch operand {
-	case True:
-		return vm.push(False)
-	case False, Null:
-		return vm.push(True)
-	default:
-		return vm.push(False)
-	}
+// isNumber reports whether obj is one of the numeric types that arithmetic and
+// ordering comparisons operate on.
+func isNumber(obj object.Object) bool {
+	return object.IsNumber(obj)
 }
 
-func (vm *VM) executeMinusOperator() error {
-	operand := vm.pop()
-
-	if operand.Type() != object.INTEGER_OBJECT {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+func (vm *VM) executeBangOperator() error {
+	operand, error := vm.pop()
+	if error != nil {
+		return error
 	}
 
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+	return vm.push(object.NativeBoolToBooleanObject(!operand.Truthy()))
 }
 
-func nativeBoolToBooleanObject(input bool) *object.Boolean {
-	if input {
-		return True
+func (vm *VM) executeMinusOperator() error {
+	operand, error := vm.pop()
+	if error != nil {
+		return error
 	}
 
-	return False
-}
-
-func isTruthy(obj object.Object) bool {
-	switch obj := obj.(type) {
-	case *object.Boolean:
-		return obj.Value
-	case *object.Null:
-		return false
-	default:
-		return true
+	if !object.IsNumber(operand) {
+		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
+
+	return vm.push(object.NegateNumber(operand))
 }
 
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
@@ -436,7 +540,7 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
 
 	for index := startIndex; index < endIndex; index += 2 {
 		key := vm.stack[index]
@@ -444,15 +548,23 @@ func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 
 		pair := object.HashPair{Key: key, Value: value}
 
+		if object.IsMutableArray(key) {
+			return nil, fmt.Errorf("cannot use mutable array as hash key")
+		}
+
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, fmt.Errorf("unusable as hash key: %s at pair %d", key.Type(), (index-startIndex)/2+1)
+		}
+
+		if _, exists := hash.Pairs[hashKey.HashKey()]; exists && vm.strict {
+			return nil, fmt.Errorf("duplicate hash key at runtime")
 		}
 
-		hashedPairs[hashKey.HashKey()] = pair
+		hash.Set(hashKey.HashKey(), pair)
 	}
 
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return hash, nil
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
@@ -472,7 +584,7 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	max := int64(len(arrayObject.Elements) - 1)
 
 	if i < 0 || i > max {
-		return vm.push(Null)
+		return vm.push(object.NULL)
 	}
 
 	return vm.push(arrayObject.Elements[i])
@@ -481,6 +593,10 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 
+	if object.IsMutableArray(index) {
+		return fmt.Errorf("cannot use mutable array as hash key")
+	}
+
 	key, ok := index.(object.Hashable)
 	if !ok {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
@@ -488,12 +604,39 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
-		return vm.push(Null)
+		return vm.push(object.NULL)
 	}
 
 	return vm.push(pair.Value)
 }
 
+// executeIterKeys pushes the keys a foreach loop visits, indices for an array
+// and keys in insertion order for a hash, followed by their count.
+func (vm *VM) executeIterKeys(iterable object.Object) error {
+	var keys []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		keys = make([]object.Object, len(iterable.Elements))
+		for i := range iterable.Elements {
+			keys[i] = &object.Integer{Value: int64(i)}
+		}
+	case *object.Hash:
+		for _, pair := range iterable.OrderedPairs() {
+			keys = append(keys, pair.Key)
+		}
+	default:
+		return fmt.Errorf("cannot iterate over %s", iterable.Type())
+	}
+
+	error := vm.push(&object.Array{Elements: keys})
+	if error != nil {
+		return error
+	}
+
+	return vm.push(&object.Integer{Value: int64(len(keys))})
+}
+
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.frameIndex-1]
 }
@@ -505,9 +648,19 @@ func (vm *VM) pushFrame(f *Frame) {
 
 func (vm *VM) popFrame() *Frame {
 	vm.frameIndex--
+	vm.dropCatches()
+
 	return vm.frames[vm.frameIndex]
 }
 
+// dropCatches discards the handlers of try blocks whose frame is gone, such as
+// when a function returns from inside a try block.
+func (vm *VM) dropCatches() {
+	for len(vm.catches) > 0 && vm.catches[len(vm.catches)-1].frameIndex > vm.frameIndex {
+		vm.catches = vm.catches[:len(vm.catches)-1]
+	}
+}
+
 func (vm *VM) executeCall(numArgs int) error {
 	callee := vm.stack[vm.stackPointer-1-numArgs]
 	switch callee := callee.(type) {
@@ -525,6 +678,10 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
 	}
 
+	if vm.frameIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow (deep recursion?)")
+	}
+
 	frame := NewFrame(cl, vm.stackPointer-numArgs)
 	vm.pushFrame(frame)
 
@@ -534,18 +691,105 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
-	args := vm.stack[vm.stackPointer-numArgs : vm.stackPointer]
+	// Builtins may keep their arguments, so they get a copy instead of a window
+	// into the stack that later pushes would overwrite.
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.stackPointer-numArgs:vm.stackPointer])
 
-	result := builtin.Fn(args...)
+	outerFailed := vm.callbackFailed
+	vm.callbackFailed = false
+
+	result := vm.invokeBuiltin(builtin, args)
 	vm.stackPointer = vm.stackPointer - numArgs - 1
 
-	if result != nil {
-		vm.push(result)
+	callbackFailed := vm.callbackFailed
+	vm.callbackFailed = outerFailed
+
+	// Fatal errors always stop the program. Inside a try block a runtime error
+	// raised by a callback unwinds to the handler like any other. Error values
+	// the builtin returns on its own, such as error("x"), stay values.
+	if errorObject, ok := result.(*object.Error); ok {
+		if errorObject.Fatal {
+			return &fatalError{message: errorObject.Chain()}
+		}
+
+		if callbackFailed && len(vm.catches) > 0 {
+			return fmt.Errorf("%s", errorObject.Chain())
+		}
+	}
+
+	return vm.push(result)
+}
+
+func (vm *VM) invokeBuiltin(builtin *object.Builtin, args []object.Object) object.Object {
+	var result object.Object
+	if builtin.HigherOrderFn != nil {
+		result = builtin.HigherOrderFn(vm.callFunction, args...)
 	} else {
-		vm.push(Null)
+		result = builtin.Fn(args...)
 	}
 
-	return nil
+	if result == nil {
+		return object.NULL
+	}
+
+	return result
+}
+
+// callFunction is the object.Caller handed to higher-order builtins. Closures
+// are run to completion on top of the current stack, and any VM error is
+// returned as an *object.Error.
+func (vm *VM) callFunction(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return vm.invokeBuiltin(fn, args)
+	case *object.Closure:
+		stackPointer := vm.stackPointer
+		frameIndex := vm.frameIndex
+
+		result, error := vm.runClosure(fn, args)
+		vm.stackPointer = stackPointer
+		vm.frameIndex = frameIndex
+		vm.dropCatches()
+
+		if error != nil {
+			vm.callbackFailed = true
+			_, fatal := error.(*fatalError)
+			return &object.Error{Message: error.Error(), Fatal: fatal}
+		}
+
+		return result
+	default:
+		return &object.Error{Message: "calling non-function and non-built-in"}
+	}
+}
+
+func (vm *VM) runClosure(cl *object.Closure, args []object.Object) (object.Object, error) {
+	frameIndex := vm.frameIndex
+
+	error := vm.push(cl)
+	if error != nil {
+		return nil, error
+	}
+
+	for _, arg := range args {
+		error = vm.push(arg)
+		if error != nil {
+			return nil, error
+		}
+	}
+
+	error = vm.callClosure(cl, len(args))
+	if error != nil {
+		return nil, error
+	}
+
+	error = vm.run(frameIndex)
+	if error != nil {
+		return nil, error
+	}
+
+	return vm.pop()
 }
 
 func (vm *VM) pushClosure(constIndex, numFree int) error {
@@ -2,11 +2,16 @@ package vm
 
 import (
 	"fmt"
+	"io"
 	"monkey/ast"
+	"monkey/code"
 	"monkey/compiler"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -100,6 +105,32 @@ func runVmTests(tester *testing.T, tests []vmTestCase) {
 	}
 }
 
+// runVmErrorTests runs each program expecting the VM to fail with the error
+// message in expected.
+func runVmErrorTests(tester *testing.T, tests []vmTestCase) {
+	tester.Helper()
+
+	for _, testcase := range tests {
+		program := parse(testcase.input)
+
+		comp := compiler.New()
+		error := comp.Compile(program)
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		error = vm.Run()
+		if error == nil {
+			tester.Fatalf("expected VM error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong VM error: want=%q, got=%q", testcase.expected, error)
+		}
+	}
+}
+
 func testExpectedObject(tester *testing.T, expected interface{}, actual object.Object) {
 	tester.Helper()
 
@@ -109,6 +140,16 @@ func testExpectedObject(tester *testing.T, expected interface{}, actual object.O
 		if error != nil {
 			tester.Errorf("testIntegerObject failed: %s", error)
 		}
+	case float64:
+		float, ok := actual.(*object.Float)
+		if !ok {
+			tester.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if float.Value != expected {
+			tester.Errorf("object has wrong value. got=%g, want=%g", float.Value, expected)
+		}
 	case bool:
 		error := testBooleanObject(bool(expected), actual)
 		if error != nil {
@@ -137,6 +178,39 @@ func testExpectedObject(tester *testing.T, expected interface{}, actual object.O
 				tester.Errorf("testIntegerObject failed: %s", error)
 			}
 		}
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			tester.Errorf("object is not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			tester.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElement := range expected {
+			error := testStringObject(expectedElement, array.Elements[i])
+			if error != nil {
+				tester.Errorf("testStringObject failed: %s", error)
+			}
+		}
+	case [][]int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			tester.Errorf("object is not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			tester.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElement := range expected {
+			testExpectedObject(tester, expectedElement, array.Elements[i])
+		}
 	case map[object.HashKey]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
@@ -169,13 +243,25 @@ func testExpectedObject(tester *testing.T, expected interface{}, actual object.O
 		if errorObject.Message != expected.Message {
 			tester.Errorf("wrong error message. expected=%q, got=%q", expected.Message, errorObject.Message)
 		}
+
+		if expected.Cause != nil && errorObject.Inspect() != expected.Inspect() {
+			tester.Errorf("wrong error chain. expected=%q, got=%q", expected.Inspect(), errorObject.Inspect())
+		}
 	case *object.Null:
-		if actual != Null {
-			tester.Errorf("object is not Null: %T (%+v)", actual, actual)
+		if actual != object.NULL {
+			tester.Errorf("object is not object.NULL: %T (%+v)", actual, actual)
 		}
 	}
 }
 
+func TestEmptyProgram(tester *testing.T) {
+	tests := []vmTestCase{
+		{"", object.NULL},
+	}
+
+	runVmTests(tester, tests)
+}
+
 func TestIntegerArithmetic(tester *testing.T) {
 	tests := []vmTestCase{
 		{"1", 1},
@@ -194,9 +280,101 @@ func TestIntegerArithmetic(tester *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"255 + 256", 511},
+		{"0 - 300", -300},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 10 % 4 * 3", 8},
+		{"str(99999999999999999999 % 7)", "1"},
+		{"7.5 % 2", 1.5},
+	}
+
+	runVmTests(tester, tests)
+
+	runVmErrorTests(tester, []vmTestCase{
+		{"5 / 0", "division by zero"},
+		{"5 / (1 - 1)", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"5 % (1 - 1)", "division by zero"},
+	})
+}
+
+func TestBigIntegers(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			`let factorial = fn(n) { if (n < 2) { 1 } else { n * factorial(n - 1) } }; str(factorial(25))`,
+			"15511210043330985984000000",
+		},
+		{
+			`let factorial = fn(n) { if (n < 2) { 1 } else { n * factorial(n - 1) } }; factorial(25) / factorial(23)`,
+			600,
+		},
+		{`str(9223372036854775807 + 1)`, "9223372036854775808"},
+		{`str(-9223372036854775807 - 2)`, "-9223372036854775809"},
+		{`str(-(-9223372036854775807 - 1))`, "9223372036854775808"},
+		{`str((-9223372036854775807 - 1) / -1)`, "9223372036854775808"},
+		{`str(99999999999999999999)`, "99999999999999999999"},
+		{`99999999999999999999 - 99999999999999999998`, 1},
+		{`99999999999999999999 > 9223372036854775807`, true},
+		{`-99999999999999999999 < 1`, true},
+		{`99999999999999999999 == 99999999999999999999`, true},
+		{`99999999999999999999 == 9223372036854775807`, false},
+		{`{99999999999999999999: "big"}[99999999999999999999]`, "big"},
+		{`{-99999999999999999999: "neg"}[99999999999999999999]`, object.NULL},
+		{`try { 99999999999999999999 / 0 } catch (e) { e }`, "division by zero"},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestFloatArithmetic(tester *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1 / 2", 0},
+		{"1.0 / 2", 0.5},
+		{"1 / 2.0", 0.5},
+		{"1 + 0.5", 1.5},
+		{"2.5 * 2", 5.0},
+		{"-1.5 + 1", -0.5},
+		{"-2.5", -2.5},
+		{"1.5 > 1", true},
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"0.5 != 0.5", false},
+		{"str(2.0)", "2.0"},
+		{"str(1.0 / 4)", "0.25"},
+		{"try { 1.5 / 0 } catch (e) { e }", "division by zero"},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestLogicalOperators(tester *testing.T) {
+	crashy := `let crashyFn = fn() { 1 / 0 }; `
+
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{`1 && "monkey"`, true},
+		{"if (false) { 1 } || 0", true},
+		{"1 && if (false) { 1 }", false},
+		{"1 < 2 && 2 < 3", true},
+		{"false && true || true", true},
+		{crashy + "false && crashyFn()", false},
+		{crashy + "if (false) { 1 } && crashyFn()", false},
+		{crashy + "true || crashyFn()", true},
+		{crashy + `"yes" || crashyFn()`, true},
 	}
 
 	runVmTests(tester, tests)
+
+	runVmErrorTests(tester, []vmTestCase{
+		{crashy + "true && crashyFn()", "division by zero"},
+		{crashy + "false || crashyFn()", "division by zero"},
+	})
 }
 
 func TestBooleanExpressions(tester *testing.T) {
@@ -232,6 +410,54 @@ func TestBooleanExpressions(tester *testing.T) {
 	runVmTests(tester, tests)
 }
 
+func TestBooleansAreShared(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected *object.Boolean
+	}{
+		{"true", object.TRUE},
+		{"false", object.FALSE},
+		{"1 < 2", object.TRUE},
+		{"1 == 2", object.FALSE},
+		{"!5", object.FALSE},
+	}
+
+	for _, testcase := range tests {
+		program := parse(testcase.input)
+
+		comp := compiler.New()
+		error := comp.Compile(program)
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		error = vm.Run()
+		if error != nil {
+			tester.Fatalf("vm error: %s", error)
+		}
+
+		if vm.LastPoppedStackElem() != testcase.expected {
+			tester.Errorf("%q did not produce the shared boolean. got=%p, want=%p",
+				testcase.input, vm.LastPoppedStackElem(), testcase.expected)
+		}
+	}
+}
+
+func TestNullIsShared(tester *testing.T) {
+	tests := []vmTestCase{
+		{"", object.NULL},
+		{"if (false) { 10 }", object.NULL},
+		{"[1, 2][5]", object.NULL},
+		{"{1: 2}[3]", object.NULL},
+		{"fn() { }()", object.NULL},
+		{"puts()", object.NULL},
+		{"first([])", object.NULL},
+	}
+
+	runVmTests(tester, tests)
+}
+
 func TestConditions(tester *testing.T) {
 	tests := []vmTestCase{
 		{"if (true) { 10 }", 10},
@@ -240,14 +466,270 @@ func TestConditions(tester *testing.T) {
 		{"if (1 < 2) { 10 }", 10},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 > 2) { 10 }", Null},
-		{"if (false) { 10 }", Null},
+		{"if (1 > 2) { 10 }", object.NULL},
+		{"if (false) { 10 }", object.NULL},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) {}", object.NULL},
+		{"if (false) { 10 } else {}", object.NULL},
+		{"if (true) { let x = 1; }", object.NULL},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestStrictConditions(tester *testing.T) {
+	tests := []struct {
+		input    string
+		strict   bool
+		expected string
+	}{
+		{"if (5) {}", false, ""},
+		{"if (5) {}", true, "non-boolean condition: INTEGER"},
+		{`if ("monkey") { 1 } else { 2 }`, true, "non-boolean condition: STRING"},
+		{"if (1 < 2) { 1 } else { 2 }", true, ""},
+	}
+
+	for _, testcase := range tests {
+		program := parse(testcase.input)
+
+		comp := compiler.New()
+		error := comp.Compile(program)
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetStrict(testcase.strict)
+		error = vm.Run()
+
+		if testcase.expected == "" {
+			if error != nil {
+				tester.Errorf("unexpected VM error for %q: %s", testcase.input, error)
+			}
+			continue
+		}
+
+		if error == nil {
+			tester.Fatalf("expected VM error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong VM error: want=%q, got=%q", testcase.expected, error)
+		}
+	}
+}
+
+func TestStrictDuplicateHashKeys(tester *testing.T) {
+	input := `let first = "a"; let second = "a"; let h = {first: 1, second: 2}; h[first]`
+
+	for _, strict := range []bool{false, true} {
+		comp := compiler.New()
+		error := comp.Compile(parse(input))
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetStrict(strict)
+		error = vm.Run()
+
+		if !strict {
+			if error != nil {
+				tester.Fatalf("unexpected VM error: %s", error)
+			}
+
+			testExpectedObject(tester, 2, vm.LastPoppedStackElem())
+			continue
+		}
+
+		if error == nil {
+			tester.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if error.Error() != "duplicate hash key at runtime" {
+			tester.Errorf("wrong VM error: want=%q, got=%q", "duplicate hash key at runtime", error)
+		}
+	}
+}
+
+func TestMixedTypeOperations(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`1 == true`, false},
+		{`1 != true`, true},
+		{`1 == "1"`, false},
+		{`"1" != 1`, true},
+		{`[1] == 1`, false},
+	})
+
+	tests := []vmTestCase{
+		{`1 + true`, "unsupported types for binary operation: INTEGER BOOLEAN"},
+		{`"a" * 2`, "unsupported types for binary operation: STRING INTEGER"},
+		{`1 > "1"`, fmt.Sprintf("unknown operator: %d (INTEGER STRING)", code.OpGreaterThan)},
+		{`true > false`, fmt.Sprintf("unknown operator: %d (BOOLEAN BOOLEAN)", code.OpGreaterThan)},
+	}
+
+	runVmErrorTests(tester, tests)
+}
+
+func TestStringConcatenationWithNonStrings(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`"count: " + str(5)`, "count: 5"},
+		{`str([1, "a"]) + str(true)`, "[1, a]true"},
+		{`str("a")`, "a"},
+	})
+
+	tests := []vmTestCase{
+		{`"count: " + 5`, "cannot concatenate STRING and INTEGER (use str())"},
+		{`5 + "count"`, "cannot concatenate INTEGER and STRING (use str())"},
+		{`"a" + [1]`, "cannot concatenate STRING and ARRAY (use str())"},
+		{`true + 1`, "unsupported types for binary operation: BOOLEAN INTEGER"},
+	}
+
+	runVmErrorTests(tester, tests)
+}
+
+func TestLoopsAndUnless(tester *testing.T) {
+	tests := []vmTestCase{
+		{`let i = 0; until (i > 4) { let i = i + 1; }; i`, 5},
+		{`let i = 0; let sum = 0; while (i < 5) { let i = i + 1; let sum = sum + i; }; sum`, 15},
+		{`while (false) { 1 }`, object.NULL},
+		{
+			`let count = fn(n) { let i = 0; until (i == n) { let i = i + 1; }; i }; count(7)`,
+			7,
+		},
+		{`let first = fn() { let i = 0; while (true) { if (i == 3) { return i; }; let i = i + 1; } }; first()`, 3},
+		{`unless (1 > 2) { 10 }`, 10},
+		{`unless (1 < 2) { 10 }`, object.NULL},
+		{`unless (1 < 2) { 10 } else { 20 }`, 20},
 	}
 
 	runVmTests(tester, tests)
 }
 
+func TestForeach(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`let sum = 0; foreach (x in [1, 2, 3, 4]) { let sum = sum + x; }; sum`, 10},
+		{`let s = ""; foreach (k, v in {"a": "x", "b": "y", "c": "z"}) { let s = s + k + v; }; s`, "axbycz"},
+		{`let s = ""; foreach (v in {"a": "x", "b": "y"}) { let s = s + v; }; s`, "xy"},
+		{`let sum = 0; foreach (i, x in [10, 20, 30]) { let sum = sum + i * x; }; sum`, 80},
+		{`foreach (x in []) { x }`, object.NULL},
+		{
+			`let pairs = 0; foreach (x in [1, 2, 3]) { foreach (y in [1, 2]) { let pairs = pairs + x * y; } }; pairs`,
+			18,
+		},
+		{
+			`let total = fn(arr) { let sum = 0; foreach (x in arr) { let sum = sum + x; }; sum }; total([5, 6, 7])`,
+			18,
+		},
+		{
+			`let first = fn(arr, want) { foreach (i, x in arr) { if (x == want) { return i; } }; -1 };
+			first([4, 5, 6], 6) + first([4], 9)`,
+			1,
+		},
+		{
+			`let make = fn() { let fns = []; foreach (x in [1, 2]) { let fns = push(fns, fn() { x * 10 }); }; fns };
+			let fns = make(); fns[0]() + fns[1]()`,
+			30,
+		},
+	})
+
+	runVmErrorTests(tester, []vmTestCase{
+		{`foreach (x in 5) { x }`, "cannot iterate over INTEGER"},
+	})
+}
+
+func TestRuntimeErrorLines(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nlet b = a / 0;", "line 2: division by zero"},
+		{"let f = fn(x) {\n  x + true\n};\nf(1)", "line 2: unsupported types for binary operation: INTEGER BOOLEAN"},
+		{"let f = fn(x) { x };\n\nf(1, 2)", "line 3: wrong number of arguments: want=1, got=2"},
+		{"try { 1 / 0 } catch (e) { e };\n-true", "line 2: unsupported type for negation: BOOLEAN"},
+	}
+
+	for _, testcase := range tests {
+		comp := compiler.New()
+		comp.SetLineInfo(true)
+
+		error := comp.Compile(parse(testcase.input))
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		error = vm.Run()
+		if error == nil {
+			tester.Fatalf("expected VM error for %q but resulted in none.", testcase.input)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong VM error: want=%q, got=%q", testcase.expected, error)
+		}
+	}
+}
+
+func TestTryCatch(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`try { 10 / 0 } catch (e) { -1 }`, -1},
+		{`try { 10 / 0 } catch (e) { e }`, "division by zero"},
+		{`try { 10 / 2 } catch (e) { -1 }`, 5},
+		{`1 + try { 2 * (3 / 0) } catch (e) { 10 }`, 11},
+		{`let divide = fn(x) { 10 / x }; try { divide(0) } catch (e) { 0 }`, 0},
+		{`let safe = fn(x) { try { return 10 / x; } catch (e) { 0 } }; safe(0) + safe(5)`, 2},
+		{`let f = fn() { try { 1 / 0 } catch (e) { e } }; f()`, "division by zero"},
+		{
+			`try { try { 1 / 0 } catch (e) { 1 + true } } catch (e) { e }`,
+			"unsupported types for binary operation: INTEGER BOOLEAN",
+		},
+		{`find([1, 0, 2], fn(x) { try { 1 / x == 0 } catch (e) { true } })`, 0},
+		{`try { find([0], fn(x) { 1 / x }) } catch (e) { 0 }`, 0},
+		{
+			`try { zip_with([1], [0], fn(a, b) { a / b }) } catch (e) { e }`,
+			"callback to `zip_with` failed (caused by: division by zero)",
+		},
+		{`let f = fn(x) { find([x], fn(y) { 1 / y }) }; try { f(0) } catch (e) { -1 }`, -1},
+		{`try { is_error(error("x")) } catch (e) { "caught: " + e }`, true},
+		{`let check = fn() { is_error(len(1)) }; try { check() } catch (e) { false }`, true},
+		{`try { find([1], fn(x) { is_error(error("x")) }) } catch (e) { 0 }`, 1},
+	})
+
+	runVmErrorTests(tester, []vmTestCase{
+		{`let f = fn() { try { return 1; } catch (e) { 2 } }; f(); 1 / 0`, "division by zero"},
+		{`try { 1 } catch (e) { 2 }; 1 / 0`, "division by zero"},
+	})
+
+	// Builtins return errors as values, inside a try block or not.
+	runVmTests(tester, []vmTestCase{
+		{`try { 1 } catch (e) { 2 }; len(error("boom"))`, &object.Error{Message: "argument to `len` not supported, got ERROR"}},
+		{`try { len(1) } catch (e) { 0 }`, &object.Error{Message: "argument to `len` not supported, got INTEGER"}},
+		{`try { error("boom") } catch (e) { e }`, &object.Error{Message: "boom"}},
+	})
+}
+
+func TestFunctionComparison(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`let f = fn() { 1 }; f == f`, true},
+		{`let f = fn() { 1 }; f != f`, false},
+		{`fn() { 1 } == fn() { 1 }`, false},
+		{`let f = fn() { 1 }; let g = f; g == f`, true},
+		{`let adder = fn(x) { fn(y) { x + y } }; adder(1) == adder(1)`, false},
+		{`let f = fn() { 1 }; f == 1`, false},
+		{`let f = fn() { 1 }; f != true`, true},
+		{`len == len`, true},
+	})
+
+	tests := []vmTestCase{
+		{`let f = fn() { 1 }; f > f`, "unsupported operand type for comparison: CLOSURE"},
+		{`let f = fn() { 1 }; f < 1`, "unsupported operand type for comparison: CLOSURE"},
+		{`1 > fn() { 1 }`, "unsupported operand type for comparison: CLOSURE"},
+		{`len > 1`, "unsupported operand type for comparison: BUILTIN"},
+	}
+
+	runVmErrorTests(tester, tests)
+}
+
 func TestGlobalLetStatements(tester *testing.T) {
 	tests := []vmTestCase{
 		{"let one = 1; one", 1},
@@ -263,9 +745,28 @@ func TestStringExpressions(tester *testing.T) {
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{"\"\"\"mon\nkey\"\"\" + \"!\"", "mon\nkey!"},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestStringEquality(tester *testing.T) {
+	tests := []vmTestCase{
+		{`"x" == "x"`, true},
+		{`"x" != "x"`, false},
+		{`"x" == "y"`, false},
+		{`"x" != "y"`, true},
+		{`"mon" + "key" == "monkey"`, true},
+		{`let s = "a"; s + s == "aa"`, true},
+		{`"1" == 1`, false},
 	}
 
 	runVmTests(tester, tests)
+
+	runVmErrorTests(tester, []vmTestCase{
+		{`"b" > "a"`, fmt.Sprintf("unknown operator: %d (STRING STRING)", code.OpGreaterThan)},
+	})
 }
 
 func TestArrayLiterals(tester *testing.T) {
@@ -302,46 +803,169 @@ func TestHashLiterals(tester *testing.T) {
 	runVmTests(tester, tests)
 }
 
-func TestIndexExpressions(tester *testing.T) {
+func TestUnusableHashKey(tester *testing.T) {
 	tests := []vmTestCase{
-		{"[1, 2, 3][1]", 2},
-		{"[1, 2, 3][0 + 2]", 3},
-		{"[[1, 1, 1]][0][0]", 1},
-		{"[][0]", Null},
-		{"[1, 2, 3][99]", Null},
-		{"[1][-1]", Null},
-		{"{1: 1, 2: 2}[1]", 1},
-		{"{1: 1, 2: 2}[2]", 2},
-		{"{1: 1}[0]", Null},
-		{"{}[0]", Null},
+		{`{"name": "Monkey", fn(x) { x }: 1}`, "unusable as hash key: CLOSURE at pair 2"},
+		{`let h = {"a": 1}; {h: 1}`, "unusable as hash key: HASH at pair 1"},
+	}
+
+	runVmErrorTests(tester, tests)
+}
+
+func TestFrozenArrayHashKeys(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`let key = freeze([1, "a"]); let h = {key: "found"}; h[key]`, "found"},
+		{`let h = {freeze([1, 2]): "found"}; h[freeze([1, 2])]`, "found"},
+		{`let h = {freeze([[1], 2]): "nested"}; h[freeze([[1], 2])]`, "nested"},
+		{`let h = {freeze([1, 2]): "found"}; h[freeze([2, 1])]`, object.NULL},
+		{`let key = freeze([1]); freeze(key) == key`, true},
+		{`freeze([fn(x) { x }])`, &object.Error{Message: "cannot freeze array containing CLOSURE"}},
+	})
+
+	runVmErrorTests(tester, []vmTestCase{
+		{`{[1, 2]: 1}`, "cannot use mutable array as hash key"},
+		{`let h = {freeze([1, 2]): "found"}; h[[1, 2]]`, "cannot use mutable array as hash key"},
+	})
+}
+
+func TestBooleanHashKeys(tester *testing.T) {
+	tests := []vmTestCase{
+		{`{true: 5, false: 6}[true]`, 5},
+		{`{true: 5, false: 6}[false]`, 6},
+		{`{true: 5, false: 6}[1 < 2]`, 5},
+		{`{1 > 2: 5}[!true]`, 5},
+		{`let h = {true: 5}; h[false]`, object.NULL},
+		{`len({true: 1, 1 == 1: 2})`, 1},
+		{`{true: 5, 1: 6}[1]`, 6},
 	}
 
 	runVmTests(tester, tests)
 }
 
-func TestCallingFunctionWithoutArguments(tester *testing.T) {
+func TestConcatenatedStringHashKeys(tester *testing.T) {
 	tests := []vmTestCase{
-		{
-			input:    "let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();",
-			expected: 15,
-		},
-		{
-			input:    "let one = fn() { 1; }; let two = fn() { 2; }; one() + two()",
-			expected: 3,
-		},
-		{
-			input:    "let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1}; c();",
-			expected: 3,
-		},
+		{`let h = {"monkey": 1}; h["mon" + "key"]`, 1},
+		{`let key = "mon" + "key"; let h = {key: 2}; h["monkey"]`, 2},
+		{`let join = fn(a, b) { a + b }; let h = {"monkey": 3}; h[join("mon", "key")]`, 3},
+		{`let h = {"monkey": 4}; h["mon" + "keys"]`, object.NULL},
 	}
 
 	runVmTests(tester, tests)
 }
 
-func TestFunctionsWithReturnStatement(tester *testing.T) {
+func TestHashLiteralsWithComputedKeys(tester *testing.T) {
 	tests := []vmTestCase{
 		{
-			input:    "let earlyExit = fn() {return 99; 100; }; earlyExit();",
+			`let k = "a"; {k: 1, "b": 2}`,
+			map[object.HashKey]int64{
+				(&object.String{Value: "a"}).HashKey(): 1,
+				(&object.String{Value: "b"}).HashKey(): 2,
+			},
+		},
+		{
+			// Sorting the keys by their source text would emit "b" before k
+			// and let the earlier pair win.
+			`let k = "b"; {k: 1, "b": 2}["b"]`,
+			2,
+		},
+		{
+			`let k = "b"; {"b": 1, k: 2}["b"]`,
+			2,
+		},
+		{
+			`let k = "a"; keys({"z": 1, k: 2, "m": 3})`,
+			[]string{"z", "a", "m"},
+		},
+		{
+			`let key = fn(x) { x * 10 }; let h = {key(2): 1, key(1): 2}; [h[20], h[10]]`,
+			[]int{1, 2},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestEmptyCollections(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[]", []int{}},
+		{"{}", map[object.HashKey]int64{}},
+		{"[[], {}, []]; [[]]", [][]int{{}}},
+		{"let a = []; let h = {}; push(a, 1)", []int{1}},
+	}
+
+	for _, testcase := range tests {
+		program := parse(testcase.input)
+
+		comp := compiler.New()
+		error := comp.Compile(program)
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		vm := New(comp.Bytecode())
+		error = vm.Run()
+		if error != nil {
+			tester.Fatalf("vm error: %s", error)
+		}
+
+		if vm.stackPointer != 0 {
+			tester.Errorf("stack pointer not restored after %q. got=%d", testcase.input, vm.stackPointer)
+		}
+
+		testExpectedObject(tester, testcase.expected, vm.LastPoppedStackElem())
+	}
+
+	for _, op := range []code.Opcode{code.OpArray, code.OpHash} {
+		error := runHandBuiltBytecode(nil, code.Make(op, 2), code.Make(code.OpPop))
+		if error == nil || error.Error() != "stack underflow" {
+			tester.Errorf("expected stack underflow building from an empty stack. got=%v", error)
+		}
+	}
+}
+
+func TestIndexExpressions(tester *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][0 + 2]", 3},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", object.NULL},
+		{"[1, 2, 3][99]", object.NULL},
+		{"[1][-1]", object.NULL},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", object.NULL},
+		{"{}[0]", object.NULL},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestCallingFunctionWithoutArguments(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			input:    "let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();",
+			expected: 15,
+		},
+		{
+			input:    "let one = fn() { 1; }; let two = fn() { 2; }; one() + two()",
+			expected: 3,
+		},
+		{
+			input:    "let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1}; c();",
+			expected: 3,
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestFunctionsWithReturnStatement(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			input:    "let earlyExit = fn() {return 99; 100; }; earlyExit();",
 			expected: 99,
 		},
 		{
@@ -357,11 +981,23 @@ func TestFunctionsWithoutReturnValue(tester *testing.T) {
 	tests := []vmTestCase{
 		{
 			input:    "let noReturn = fn() { }; noReturn();",
-			expected: Null,
+			expected: object.NULL,
 		},
 		{
 			input:    "let noReturn = fn() { }; let noReturnTwo = fn() { noReturn(); }; noReturn(); noReturnTwo();",
-			expected: Null,
+			expected: object.NULL,
+		},
+		{
+			input:    "fn() { let x = 1; }();",
+			expected: object.NULL,
+		},
+		{
+			input:    "let onlyLet = fn() { let x = 99; }; let caller = fn() { let y = onlyLet(); 7 }; caller();",
+			expected: 7,
+		},
+		{
+			input:    "let onlyLet = fn(a) { let x = a * 2; }; [onlyLet(1), 5][1];",
+			expected: 5,
 		},
 	}
 
@@ -444,6 +1080,46 @@ func TestCallingFunctionsWithArgumentsAndBindings(tester *testing.T) {
 	runVmTests(tester, tests)
 }
 
+func TestCallingFunctionsFromCollections(tester *testing.T) {
+	tests := []vmTestCase{
+		{`let ops = {"add": fn(a, b) { a + b }}; ops["add"](1, 2)`, 3},
+		{`let ops = [fn(x) { x * 2 }, fn(x) { x - 1 }]; ops[0](21) + ops[1](1)`, 42},
+		{`[fn() { 5 }][0]()`, 5},
+		{`let adder = fn(n) { fn(x) { x + n } }; let ops = {"inc": adder(1)}; ops["inc"](41)`, 42},
+		{`let ops = {"len": len}; ops["len"]("four")`, 4},
+		{`let table = {"ops": [fn(a, b) { a - b }]}; table["ops"][0](10, 3)`, 7},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestKeywordArguments(tester *testing.T) {
+	tests := []vmTestCase{
+		{`let sub = fn(x, y) { x - y }; sub(y: 1, x: 3);`, 2},
+		{`fn(a, b, c) { [a, b, c] }(1, c: 3, b: 2);`, []int{1, 2, 3}},
+		{
+			`let outer = fn(base) {
+				let scale = fn(value, by) { value * by + base };
+				fn() { scale(by: 2, value: 5) }
+			};
+			outer(1)();`,
+			11,
+		},
+		{`let countdown = fn(n, step) { if (n > 0) { countdown(step: step, n: n - step) } else { n } }; countdown(step: 3, n: 10);`, -2},
+		{`let sub = fn(x, y) { x - y }; sub(y: sub(y: 1, x: 5), x: 10);`, 6},
+		{`let sub = fn(x, y) { x - y }; fn(a) { let b = 1; sub(y: a, x: b) }(5);`, -4},
+	}
+
+	runVmTests(tester, tests)
+
+	// Keyword arguments are evaluated in source order, so the first failing
+	// one is the first written rather than the first parameter.
+	runVmErrorTests(tester, []vmTestCase{
+		{`let sub = fn(x, y) { x - y }; sub(y: 1 / 0, x: -true);`, "division by zero"},
+		{`let sub = fn(x, y) { x - y }; sub(y: -true, x: 1 / 0);`, "unsupported type for negation: BOOLEAN"},
+	})
+}
+
 func TestCallingFunctionsWithWrongArguments(tester *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -499,23 +1175,30 @@ func TestBuiltinFunctions(tester *testing.T) {
 		},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
-		{`puts("hello", "world!")`, Null},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len({})`, 0},
+		{`len(fn() { 1 })`,
+			&object.Error{
+				Message: "argument to `len` not supported, got CLOSURE",
+			},
+		},
+		{`puts("hello", "world!")`, object.NULL},
 		{`first([1, 2, 3])`, 1},
-		{`first([])`, Null},
+		{`first([])`, object.NULL},
 		{`first(1)`,
 			&object.Error{
 				Message: "argument to `first` must be ARRAY, got INTEGER",
 			},
 		},
 		{`last([1, 2, 3])`, 3},
-		{`last([])`, Null},
+		{`last([])`, object.NULL},
 		{`last(1)`,
 			&object.Error{
 				Message: "argument to `last` must be ARRAY, got INTEGER",
 			},
 		},
 		{`rest([1, 2, 3])`, []int{2, 3}},
-		{`rest([])`, Null},
+		{`rest([])`, object.NULL},
 		{`push([], 1)`, []int{1}},
 		{`push(1, 1)`,
 			&object.Error{
@@ -527,125 +1210,953 @@ func TestBuiltinFunctions(tester *testing.T) {
 	runVmTests(tester, tests)
 }
 
-func TestClosures(tester *testing.T) {
+func TestBuiltinArgumentsAreNotAliased(tester *testing.T) {
+	keep := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.Array{Elements: args}
+	}}
+
+	vm := New(&compiler.Bytecode{})
+	for _, value := range []object.Object{keep, &object.Integer{Value: 1}, &object.Integer{Value: 2}} {
+		if error := vm.push(value); error != nil {
+			tester.Fatalf("push failed: %s", error)
+		}
+	}
+
+	error := vm.callBuiltin(keep, 2)
+	if error != nil {
+		tester.Fatalf("callBuiltin failed: %s", error)
+	}
+
+	kept, error := vm.pop()
+	if error != nil {
+		tester.Fatalf("pop failed: %s", error)
+	}
+
+	for _, value := range []object.Object{object.TRUE, object.TRUE, object.TRUE} {
+		if error := vm.push(value); error != nil {
+			tester.Fatalf("push failed: %s", error)
+		}
+	}
+
+	testExpectedObject(tester, []int{1, 2}, kept)
+}
+
+func TestDumpBytecode(tester *testing.T) {
+	comp := compiler.New()
+	error := comp.Compile(parse(`let add = fn(a, b) { a + b }; dump_bytecode(add)`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	vm := New(comp.Bytecode())
+	if error := vm.Run(); error != nil {
+		tester.Fatalf("vm error: %s", error)
+	}
+
+	dump, ok := vm.LastPoppedStackElem().(*object.String)
+	if !ok {
+		tester.Fatalf("object is not String. got=%T (%+v)", vm.LastPoppedStackElem(), vm.LastPoppedStackElem())
+	}
+
+	for _, expected := range []string{"0000 OpGetLocal 0", "OpGetLocal 1", "OpAdd", "OpReturnValue"} {
+		if !strings.Contains(dump.Value, expected) {
+			tester.Errorf("dump does not contain %q. got=%q", expected, dump.Value)
+		}
+	}
+
+	runVmTests(tester, []vmTestCase{
+		{`dump_bytecode(1)`, &object.Error{Message: "argument to `dump_bytecode` must be a compiled function, got INTEGER"}},
+		{`dump_bytecode(len)`, &object.Error{Message: "argument to `dump_bytecode` must be a compiled function, got BUILTIN"}},
+	})
+}
+
+func TestStringCaseBuiltins(tester *testing.T) {
 	tests := []vmTestCase{
-		{
-			input:    "let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();",
-			expected: 99,
+		{`upper("monkey")`, "MONKEY"},
+		{`lower("MONKEY")`, "monkey"},
+		{`upper("Hello, World")`, "HELLO, WORLD"},
+		{`lower("Hello, World")`, "hello, world"},
+		{`upper("café")`, "CAFÉ"},
+		{`lower("ÉCOLE")`, "école"},
+		{`upper(1)`,
+			&object.Error{
+				Message: "argument to `upper` must be STRING, got INTEGER",
+			},
 		},
-		{
-			input: `
-            let newAdder = fn(a, b) {
-                fn(c) { a + b + c };
-            };
-            let adder = newAdder(1, 2);
-            adder(8);
-            `,
-			expected: 11,
+		{`lower([])`,
+			&object.Error{
+				Message: "argument to `lower` must be STRING, got ARRAY",
+			},
 		},
-		{
-			input: `
-            let newAdder = fn(a, b) {
-                let c = a + b;
-                fn(d) { c + d };
-            };
-            let adder = newAdder(1, 2);
-            adder(8);
-            `,
-			expected: 11,
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestStringFromCodePoints(tester *testing.T) {
+	tests := []vmTestCase{
+		{`string([72, 105])`, "Hi"},
+		{`string([])`, ""},
+		{`string([233, 128512])`, "é😀"},
+		{`string([72, -1])`,
+			&object.Error{
+				Message: "invalid code point -1 in argument to `string`",
+			},
 		},
-		{
-			input: `
-            let newAdderOuter = fn(a, b) {
-                let c = a + b;
-                fn(d) {
-                    let e = d + c;
-                    fn(f) { e + f; };
-                };
-            };
-            let newAdderInner = newAdderOuter(1, 2)
-            let adder = newAdderInner(3);
-            adder(8);
-            `,
-			expected: 14,
+		{`string([1114112])`,
+			&object.Error{
+				Message: "invalid code point 1114112 in argument to `string`",
+			},
 		},
-		{
-			input: `
-            let a = 1;
-            let newAdderOuter = fn(b) {
-                fn(c) {
-                    fn(d) { a + b + c + d };
-                };
-            };
-            let newAdderInner = newAdderOuter(2)
-            let adder = newAdderInner(3);
-            adder(8);
-            `,
-			expected: 14,
+		{`string([72, "i"])`,
+			&object.Error{
+				Message: "elements of `string` argument must be INTEGER, got STRING",
+			},
 		},
-		{
-			input: `
-            let newClosure = fn(a, b) {
-                let one = fn() { a; };
-                let two = fn() { b; };
-                fn() { one() + two(); };
-            };
-            let closure = newClosure(9, 90);
-            closure();
-            `,
-			expected: 99,
+		{`string("Hi")`,
+			&object.Error{
+				Message: "argument to `string` must be ARRAY, got STRING",
+			},
 		},
 	}
 
 	runVmTests(tester, tests)
 }
 
-func TestRecursiveFunctions(tester *testing.T) {
+func TestTakeAndDrop(tester *testing.T) {
 	tests := []vmTestCase{
-		{
-			input: `
-            let countDown = fn(x) {
-                if (x == 0) {
-                    return 0;
-                } else {
-                    countDown(x - 1);
-                }
-            };
-            countDown(1);
-            `,
-			expected: 0,
+		{`take([1, 2, 3], 2)`, []int{1, 2}},
+		{`take([1, 2, 3], 0)`, []int{}},
+		{`take([1, 2, 3], 10)`, []int{1, 2, 3}},
+		{`take([], 1)`, []int{}},
+		{`drop([1, 2, 3], 1)`, []int{2, 3}},
+		{`drop([1, 2, 3], 0)`, []int{1, 2, 3}},
+		{`drop([1, 2, 3], 10)`, []int{}},
+		{`let a = [1, 2, 3]; drop(a, 1); a`, []int{1, 2, 3}},
+		{`take([1, 2, 3], -1)`,
+			&object.Error{
+				Message: "second argument to `take` must not be negative, got -1",
+			},
 		},
-		{
-			input: `
-            let countDown = fn(x) {
-                if (x == 0) {
-                    return 0;
-                } else {
-                    countDown(x - 1);
-                }
-            };
-            let wrapper = fn() {
-                countDown(1);
-            };
-            wrapper();
-            `,
-			expected: 0,
+		{`drop([1, 2, 3], -2)`,
+			&object.Error{
+				Message: "second argument to `drop` must not be negative, got -2",
+			},
 		},
-		{
-			input: `
-            let wrapper = fn() {
-                let countDown = fn(x) {
-                    if (x == 0) {
-                        return 0;
-                    } else {
-                        countDown(x - 1);
-                    }
-                }
-                countDown(1);
-            }
-            wrapper();
-            `,
-			expected: 0,
+		{`take("abc", 1)`,
+			&object.Error{
+				Message: "argument to `take` must be ARRAY, got STRING",
+			},
+		},
+		{`drop([1], "1")`,
+			&object.Error{
+				Message: "second argument to `drop` must be INTEGER, got STRING",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestPartitionAndGroupBy(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			`partition([1, 2, 3, 4, 5], fn(x) { x > 2 })`,
+			[][]int{{3, 4, 5}, {1, 2}},
+		},
+		{`partition([], fn(x) { true })`, [][]int{{}, {}}},
+		{
+			`let even = fn(x) { x / 2 * 2 == x }; partition([1, 2, 3, 4], even)`,
+			[][]int{{2, 4}, {1, 3}},
+		},
+		{`partition([1], fn(a, b) { a })`,
+			&object.Error{
+				Message: "wrong number of callback arguments for `partition`: want=1, got=2",
+			},
+		},
+		{`partition([1], fn(x) { x + "a" })`,
+			&object.Error{
+				Message: "callback to `partition` failed",
+				Cause:   &object.Error{Message: "cannot concatenate INTEGER and STRING (use str())"},
+			},
+		},
+		{`partition(1, fn(x) { x })`,
+			&object.Error{
+				Message: "argument to `partition` must be ARRAY, got INTEGER",
+			},
+		},
+		{`partition([1], 1)`,
+			&object.Error{
+				Message: "callback to `partition` must be a function, got INTEGER",
+			},
+		},
+		{
+			`let size = fn(x) { if (x > 2) { "big" } else { "small" } };
+			let groups = group_by([1, 2, 3, 4, 5], size);
+			[groups["small"], groups["big"]]`,
+			[][]int{{1, 2}, {3, 4, 5}},
+		},
+		{`group_by([1, 2, 3], fn(x) { x })[2]`, []int{2}},
+		{`group_by([1], fn() { 1 })`,
+			&object.Error{
+				Message: "wrong number of callback arguments for `group_by`: want=1, got=0",
+			},
+		},
+		{`group_by([1], fn(x) { [x] })`,
+			&object.Error{
+				Message: "unusable as hash key: ARRAY",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestRepeat(tester *testing.T) {
+	tests := []vmTestCase{
+		{`repeat(7, 3)`, []int{7, 7, 7}},
+		{`repeat("ab", 2)`, []string{"ab", "ab"}},
+		{`repeat(1, 0)`, []int{}},
+		{`repeat([1, 2], 2)`, [][]int{{1, 2}, {1, 2}}},
+		// Copies of a frozen array stay frozen, so they still work as hash keys.
+		{`let k = freeze([1, 2]); let h = {k: "pair"}; h[repeat(k, 1)[0]]`, "pair"},
+		{`let k = freeze([1, 2]); let h = {k: "pair"}; h[repeat([k], 1)[0][0]]`, "pair"},
+		{`repeat(1, -1)`,
+			&object.Error{
+				Message: "second argument to `repeat` must not be negative, got -1",
+			},
+		},
+		{`repeat(1, "2")`,
+			&object.Error{
+				Message: "second argument to `repeat` must be INTEGER, got STRING",
+			},
+		},
+		{`repeat(1)`,
+			&object.Error{
+				Message: "wrong number of arguments. got=1, want=2",
+			},
+		},
+		{`repeat([1], 100000000000000)`,
+			&object.Error{
+				Message: "second argument to `repeat` must be at most 16777216, got 100000000000000",
+			},
+		},
+		{`repeat("ab", 4611686018427387904)`,
+			&object.Error{
+				Message: "second argument to `repeat` must be at most 16777216, got 4611686018427387904",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestRegexpBuiltins(tester *testing.T) {
+	tests := []vmTestCase{
+		{`matches("monkey42", "[a-z]+[0-9]+")`, true},
+		{`matches("monkey", "^[0-9]+$")`, false},
+		{`find_all("a1 b22 c333", "[0-9]+")`, []string{"1", "22", "333"}},
+		{`find_all("monkey", "[0-9]+")`, []string{}},
+		{`matches("monkey", "(")`,
+			&object.Error{
+				Message: "invalid pattern for `matches`: error parsing regexp: missing closing ): `(`",
+			},
+		},
+		{`find_all("monkey", "[a-")`,
+			&object.Error{
+				Message: "invalid pattern for `find_all`: error parsing regexp: missing closing ]: `[a-`",
+			},
+		},
+		{`matches(1, "a")`,
+			&object.Error{
+				Message: "argument to `matches` must be STRING, got INTEGER",
+			},
+		},
+		{`find_all("a", 1)`,
+			&object.Error{
+				Message: "second argument to `find_all` must be STRING, got INTEGER",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestMinByAndMaxBy(tester *testing.T) {
+	tests := []vmTestCase{
+		{`min_by([3, -5, 2], fn(x) { x * x })`, 2},
+		{`max_by([3, -5, 2], fn(x) { x * x })`, -5},
+		{`max_by([[1], [1, 2, 3], [4, 5]], fn(a) { len(a) })`, []int{1, 2, 3}},
+		{`min_by(["pear", "fig", "apple"], fn(s) { s })`, "apple"},
+		{`max_by([1, 2, 3, 4], fn(x) { x / 2 })`, 4},
+		{`min_by([1, 2, 3, 4], fn(x) { x / 2 })`, 1},
+		{`min_by([], fn(x) { x })`, object.NULL},
+		{`max_by([1, 2], fn(x) { if (x == 1) { 1 } else { "two" } })`,
+			&object.Error{
+				Message: "keys returned by `max_by` callback are not comparable: INTEGER and STRING",
+			},
+		},
+		{`min_by([1], fn(x) { [x] })`,
+			&object.Error{
+				Message: "key returned by `min_by` callback must be INTEGER or STRING, got ARRAY",
+			},
+		},
+		{`min_by([1], fn(a, b) { a })`,
+			&object.Error{
+				Message: "wrong number of callback arguments for `min_by`: want=1, got=2",
+			},
+		},
+		{`max_by(1, fn(x) { x })`,
+			&object.Error{
+				Message: "argument to `max_by` must be ARRAY, got INTEGER",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestErrorBuiltins(tester *testing.T) {
+	tests := []vmTestCase{
+		{`error("boom")`, &object.Error{Message: "boom"}},
+		{`is_error(error("x"))`, true},
+		{`is_error("x")`, false},
+		{`is_error(len(1))`, true},
+		{`let check = fn(x) { if (x < 0) { error("negative") } else { x } }; is_error(check(-1))`, true},
+		{`error(1)`, &object.Error{Message: "argument to `error` must be STRING, got INTEGER"}},
+		{`is_error()`, &object.Error{Message: "wrong number of arguments. got=0, want=1"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestSprintf(tester *testing.T) {
+	tests := []vmTestCase{
+		{`sprintf("no verbs")`, "no verbs"},
+		{`sprintf("%d apples", 3)`, "3 apples"},
+		{`sprintf("hello, %s!", "monkey")`, "hello, monkey!"},
+		{`sprintf("%t or %t", true, 1 > 2)`, "true or false"},
+		{`sprintf("100%%")`, "100%"},
+		{`sprintf("%s=%d (%d%%)", "x", -5, 50)`, "x=-5 (50%)"},
+		{`sprintf("%d!", 9223372036854775807 + 1)`, "9223372036854775808!"},
+		{`sprintf("%d", "five")`,
+			&object.Error{Message: "argument 1 to `sprintf` must be INTEGER for %d, got STRING"},
+		},
+		{`sprintf("%s %t", "a", "b")`,
+			&object.Error{Message: "argument 2 to `sprintf` must be BOOLEAN for %t, got STRING"},
+		},
+		{`sprintf("%d and %d", 1)`, &object.Error{Message: "missing argument for %d in `sprintf` format"}},
+		{`sprintf("%d", 1, 2)`,
+			&object.Error{Message: "too many arguments for `sprintf` format: want=1, got=2"},
+		},
+		{`sprintf("%x", 1)`, &object.Error{Message: "unsupported verb %x in `sprintf` format"}},
+		{`sprintf("50%")`, &object.Error{Message: "format for `sprintf` ends with a lone %"}},
+		{`sprintf(1)`, &object.Error{Message: "argument to `sprintf` must be STRING, got INTEGER"}},
+		{`sprintf()`, &object.Error{Message: "wrong number of arguments. got=0, want at least 1"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestBoolBuiltin(tester *testing.T) {
+	// Only false and null are falsy; zero and empty collections are truthy.
+	tests := []vmTestCase{
+		{`bool(0)`, true},
+		{`bool("")`, true},
+		{`bool([])`, true},
+		{`bool({})`, true},
+		{`bool(if (false) { 1 })`, false},
+		{`bool(false)`, false},
+		{`bool(true)`, true},
+		{`bool(1)`, true},
+		{`bool("monkey")`, true},
+		{`bool(fn() { 1 })`, true},
+		{`bool(1, 2)`, &object.Error{Message: "wrong number of arguments. got=2, want=1"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestFloorDivision(tester *testing.T) {
+	tests := []vmTestCase{
+		{`7 / 2`, 3},
+		{`floordiv(7, 2)`, 3},
+		{`-7 / 2`, -3},
+		{`floordiv(-7, 2)`, -4},
+		{`7 / -2`, -3},
+		{`floordiv(7, -2)`, -4},
+		{`-7 / -2`, 3},
+		{`floordiv(-7, -2)`, 3},
+		{`floordiv(-8, 2)`, -4},
+		{`floordiv(0, -3)`, 0},
+		{`str(floordiv(-9223372036854775807 - 1, -1))`, "9223372036854775808"},
+		{`floordiv(9223372036854775807 + 11, 2)`, 4611686018427387909},
+		{`str(floordiv(-(9223372036854775807 + 2), 2))`, "-4611686018427387905"},
+		{`floordiv(1, 0)`, &object.Error{Message: "division by zero"}},
+		{`floordiv(1, "2")`, &object.Error{Message: "arguments to `floordiv` must be INTEGER, got STRING"}},
+		{`floordiv(1)`, &object.Error{Message: "wrong number of arguments. got=1, want=2"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestDivmod(tester *testing.T) {
+	tests := []vmTestCase{
+		{`divmod(7, 2)`, []int{3, 1}},
+		{`divmod(6, 3)`, []int{2, 0}},
+		{`divmod(-7, 2)`, []int{-3, -1}},
+		{`divmod(7, -2)`, []int{-3, 1}},
+		{`divmod(-7, -2)`, []int{3, -1}},
+		{`divmod(0, 5)`, []int{0, 0}},
+		{`let pair = divmod(17, 5); pair[0] * 5 + pair[1]`, 17},
+		{`str(divmod(-9223372036854775807 - 1, -1))`, "[9223372036854775808, 0]"},
+		{`divmod(1, 0)`, &object.Error{Message: "division by zero"}},
+		{`divmod(-1, 0)`, &object.Error{Message: "division by zero"}},
+		{`divmod(1, 2.0)`, &object.Error{Message: "arguments to `divmod` must be INTEGER, got FLOAT"}},
+		{`divmod(1)`, &object.Error{Message: "wrong number of arguments. got=1, want=2"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestZipWith(tester *testing.T) {
+	tests := []vmTestCase{
+		{`zip_with([1, 2, 3], [10, 20, 30], fn(a, b) { a + b })`, []int{11, 22, 33}},
+		{`zip_with([1, 2, 3], [10, 20], fn(a, b) { a * b })`, []int{10, 40}},
+		{`zip_with([], [1], fn(a, b) { a })`, []int{}},
+		{`zip_with(["a", "b"], ["x", "y"], fn(a, b) { a + b })`, []string{"ax", "by"}},
+		{`zip_with([1, 0], [1, 1], fn(a, b) { b / a })`,
+			&object.Error{Message: "callback to `zip_with` failed", Cause: &object.Error{Message: "division by zero"}}},
+		{`zip_with([1], [2], fn(a) { a })`,
+			&object.Error{Message: "wrong number of callback arguments for `zip_with`: want=2, got=1"},
+		},
+		{`zip_with([1], [2], 3)`,
+			&object.Error{Message: "callback to `zip_with` must be a function, got INTEGER"},
+		},
+		{`zip_with([1], "b", fn(a, b) { a })`,
+			&object.Error{Message: "arguments to `zip_with` must be ARRAY, got STRING"},
+		},
+		{`zip_with([1], [2])`, &object.Error{Message: "wrong number of arguments. got=2, want=3"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestUnique(tester *testing.T) {
+	tests := []vmTestCase{
+		{`unique([3, 1, 3, 2, 1])`, []int{3, 1, 2}},
+		{`unique(["b", "a", "b", "c", "a"])`, []string{"b", "a", "c"}},
+		{`unique([[1, 2], [3], [1, 2], [3], []])`, [][]int{{1, 2}, {3}, {}}},
+		{`unique([1, 2, 3])`, []int{1, 2, 3}},
+		{`unique([])`, []int{}},
+		{`len(unique([1, "1", 1, "1"]))`, 2},
+		{`unique("abc")`, &object.Error{Message: "argument to `unique` must be ARRAY, got STRING"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestSets(tester *testing.T) {
+	tests := []vmTestCase{
+		{`let s = set([1, 2, 2, 3]); len(s)`, 3},
+		{`set_has(set(["a", "b"]), "b")`, true},
+		{`set_has(set_remove(set(["a", "b"]), "b"), "b")`, false},
+		{`let s = set([]); let t = set_add(set_add(s, 1), 1); len(t) * 10 + len(s)`, 10},
+		{`str(set_add(set([true]), false))`, "set([true, false])"},
+		{`set([fn() { 1 }])`, &object.Error{Message: "unusable as set element: CLOSURE"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestAssert(tester *testing.T) {
+	tests := []vmTestCase{
+		{`assert(1 < 2)`, true},
+		{`assert(false, 1)`, &object.Error{Message: "second argument to `assert` must be STRING, got INTEGER"}},
+		{`assert()`, &object.Error{Message: "wrong number of arguments. got=0, want=1 or 2"}},
+	}
+
+	runVmTests(tester, tests)
+
+	// A failed assertion stops the program wherever it happens, even inside
+	// a try block.
+	runVmErrorTests(tester, []vmTestCase{
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "nope")`, "assertion failed: nope"},
+		{`let check = fn() { assert(1 == 2, "inner"); true }; check(); 1`, "assertion failed: inner"},
+		{`if (true) { assert(false, "in if"); 1 }`, "assertion failed: in if"},
+		{`try { assert(false, "in try") } catch (e) { 0 }`, "assertion failed: in try"},
+		{
+			`try { each([1], fn(x) { assert(x > 1, "each") }) } catch (e) { 0 }`,
+			"callback to `each` failed (caused by: assertion failed: each)",
+		},
+	})
+}
+
+func TestChunk(tester *testing.T) {
+	tests := []vmTestCase{
+		{`chunk([1, 2, 3, 4], 2)`, [][]int{{1, 2}, {3, 4}}},
+		{`chunk([1, 2, 3, 4, 5], 2)`, [][]int{{1, 2}, {3, 4}, {5}}},
+		{`chunk([1, 2], 5)`, [][]int{{1, 2}}},
+		{`chunk([], 3)`, []int{}},
+		{`chunk([1, 2], 0)`, &object.Error{Message: "second argument to `chunk` must be positive, got 0"}},
+		{`chunk([1, 2], -1)`, &object.Error{Message: "second argument to `chunk` must be positive, got -1"}},
+		{`chunk("ab", 1)`, &object.Error{Message: "argument to `chunk` must be ARRAY, got STRING"}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestScanAndPrefixSum(tester *testing.T) {
+	tests := []vmTestCase{
+		{`scan([1, 2, 3], fn(acc, x) { acc + x })`, []int{1, 3, 6}},
+		{`scan([1, 2, 3, 4], fn(acc, x) { acc * x })`, []int{1, 2, 6, 24}},
+		{`scan(["a", "b"], fn(acc, x) { acc + x })`, []string{"a", "ab"}},
+		{`scan([], fn(acc, x) { acc + x })`, []int{}},
+		{`scan([1, "a"], fn(acc, x) { acc + x })`,
+			&object.Error{
+				Message: "callback to `scan` failed",
+				Cause:   &object.Error{Message: "cannot concatenate INTEGER and STRING (use str())"},
+			}},
+		{`scan([1], fn(x) { x })`,
+			&object.Error{Message: "wrong number of callback arguments for `scan`: want=2, got=1"}},
+		{`prefix_sum([1, 2, 3])`, []int{1, 3, 6}},
+		{`prefix_sum([])`, []int{}},
+		{`prefix_sum([1, "2"])`, &object.Error{Message: "elements of `prefix_sum` must be INTEGER, got STRING"}},
+		{`str(prefix_sum([9223372036854775807, 1]))`, "[9223372036854775807, 9223372036854775808]"},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestCallbackErrorCause(tester *testing.T) {
+	tests := []vmTestCase{
+		{`find([0], fn(x) { 1 / x })`,
+			&object.Error{
+				Message: "callback to `find` failed",
+				Cause:   &object.Error{Message: "division by zero"},
+			}},
+		{`each([[0]], fn(row) { find_index(row, fn(x) { 1 / x }) })`,
+			&object.Error{
+				Message: "callback to `each` failed",
+				Cause: &object.Error{
+					Message: "callback to `find_index` failed",
+					Cause:   &object.Error{Message: "division by zero"},
+				},
+			}},
+		{`min_by([1], fn(x) { -true })`,
+			&object.Error{
+				Message: "callback to `min_by` failed",
+				Cause:   &object.Error{Message: "unsupported type for negation: BOOLEAN"},
+			}},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestEach(tester *testing.T) {
+	tests := []vmTestCase{
+		{`each([1, 2, 3], fn(x) { x * 2 })`, object.NULL},
+		{`each([], fn(x) { x })`, object.NULL},
+		{`each([1, "a"], fn(x) { x + 1 })`,
+			&object.Error{
+				Message: "callback to `each` failed",
+				Cause:   &object.Error{Message: "cannot concatenate STRING and INTEGER (use str())"},
+			}},
+		{`each(1, fn(x) { x })`, &object.Error{Message: "argument to `each` must be ARRAY, got INTEGER"}},
+		{`each([1], fn(x, y) { x })`,
+			&object.Error{Message: "wrong number of callback arguments for `each`: want=1, got=2"}},
+	}
+
+	runVmTests(tester, tests)
+
+	output := captureStdout(tester, func() {
+		runVmTests(tester, []vmTestCase{{`each([1, 2, 3], puts)`, object.NULL}})
+	})
+
+	if !strings.HasSuffix(output, "1\n2\n3\n") {
+		tester.Errorf("each did not visit every element in order. got=%q", output)
+	}
+}
+
+// captureStdout returns what fn writes to standard output, which is where the
+// puts builtin prints.
+func captureStdout(tester *testing.T, fn func()) string {
+	tester.Helper()
+
+	reader, writer, error := os.Pipe()
+	if error != nil {
+		tester.Fatalf("could not create pipe: %s", error)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	writer.Close()
+
+	output, error := io.ReadAll(reader)
+	if error != nil {
+		tester.Fatalf("could not read captured output: %s", error)
+	}
+
+	return string(output)
+}
+
+func TestFindAndFindIndex(tester *testing.T) {
+	tests := []vmTestCase{
+		{`find([1, 2, 3, 4], fn(x) { x > 2 })`, 3},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, object.NULL},
+		{`find([], fn(x) { true })`, object.NULL},
+		{`find_index([1, 2, 3, 4], fn(x) { x > 2 })`, 2},
+		{`find_index([1, 2, 3], fn(x) { x > 5 })`, -1},
+		{`find_index([], fn(x) { true })`, -1},
+		{`let calls = fn(x) { if (x == 2) { x + "a" } else { false } }; find([1, 2, 3], calls)`,
+			&object.Error{
+				Message: "callback to `find` failed",
+				Cause:   &object.Error{Message: "cannot concatenate INTEGER and STRING (use str())"},
+			},
+		},
+		{`find([1], fn(a, b) { a })`,
+			&object.Error{
+				Message: "wrong number of callback arguments for `find`: want=1, got=2",
+			},
+		},
+		{`find_index([1], fn() { true })`,
+			&object.Error{
+				Message: "wrong number of callback arguments for `find_index`: want=1, got=0",
+			},
+		},
+		{`find_index(1, fn(x) { x })`,
+			&object.Error{
+				Message: "argument to `find_index` must be ARRAY, got INTEGER",
+			},
+		},
+		{`find([1], 1)`,
+			&object.Error{
+				Message: "callback to `find` must be a function, got INTEGER",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestHashKeysInsertionOrder(tester *testing.T) {
+	tests := []vmTestCase{
+		{`keys({})`, []string{}},
+		{`keys({"b": 1, "a": 2, "c": 3})`, []string{"b", "a", "c"}},
+		{`keys({"z": 1, "y": 2, "z": 3})`, []string{"z", "y"}},
+		{`keys({"b": 1, "a": 2, "c": 3})[0]`, "b"},
+		{`keys([1])`,
+			&object.Error{
+				Message: "argument to `keys` must be HASH, got ARRAY",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestDebugBuiltin(tester *testing.T) {
+	tests := []vmTestCase{
+		{`debug(5)`, "*object.Integer{Value: 5}"},
+		{`debug([1, "two", [3]])`, "*object.Array{Len: 3, Elements: [INTEGER, STRING, ARRAY]}"},
+		{`debug([])`, "*object.Array{Len: 0, Elements: []}"},
+		{
+			`
+            let adder = fn(a) { fn(b) { let sum = a + b; sum } };
+            debug(adder(1));
+            `,
+			"*object.Closure{NumParameters: 1, NumLocals: 2, NumFree: 1}",
+		},
+		{`debug(1, 2)`,
+			&object.Error{
+				Message: "wrong number of arguments. got=2, want=1",
+			},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestCallArgumentEvaluationOrder(tester *testing.T) {
+	logged := []object.Object{}
+	logBuiltin := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		logged = append(logged, args...)
+		return args[0]
+	}}
+
+	builtins := object.Builtins
+	object.Builtins = append(builtins[:len(builtins):len(builtins)], struct {
+		Name    string
+		Builtin *object.Builtin
+	}{"log", logBuiltin})
+	defer func() { object.Builtins = builtins }()
+
+	input := `
+	let f = fn(a, b, c) { [a, b, c] };
+	f(log(1), log(2), log(3));
+	`
+
+	runVmTests(tester, []vmTestCase{{input, []int{1, 2, 3}}})
+
+	if len(logged) != 3 {
+		tester.Fatalf("wrong number of logged values. want=3, got=%d", len(logged))
+	}
+
+	for i, value := range logged {
+		error := testIntegerObject(int64(i+1), value)
+		if error != nil {
+			tester.Errorf("argument %d evaluated out of order: %s", i, error)
+		}
+	}
+}
+
+func TestClosures(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			input:    "let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();",
+			expected: 99,
+		},
+		{
+			input: `
+            let newAdder = fn(a, b) {
+                fn(c) { a + b + c };
+            };
+            let adder = newAdder(1, 2);
+            adder(8);
+            `,
+			expected: 11,
+		},
+		{
+			input: `
+            let newAdder = fn(a, b) {
+                let c = a + b;
+                fn(d) { c + d };
+            };
+            let adder = newAdder(1, 2);
+            adder(8);
+            `,
+			expected: 11,
+		},
+		{
+			input: `
+            let newAdderOuter = fn(a, b) {
+                let c = a + b;
+                fn(d) {
+                    let e = d + c;
+                    fn(f) { e + f; };
+                };
+            };
+            let newAdderInner = newAdderOuter(1, 2)
+            let adder = newAdderInner(3);
+            adder(8);
+            `,
+			expected: 14,
+		},
+		{
+			input: `
+            let a = 1;
+            let newAdderOuter = fn(b) {
+                fn(c) {
+                    fn(d) { a + b + c + d };
+                };
+            };
+            let newAdderInner = newAdderOuter(2)
+            let adder = newAdderInner(3);
+            adder(8);
+            `,
+			expected: 14,
+		},
+		{
+			input: `
+            let newClosure = fn(a, b) {
+                let one = fn() { a; };
+                let two = fn() { b; };
+                fn() { one() + two(); };
+            };
+            let closure = newClosure(9, 90);
+            closure();
+            `,
+			expected: 99,
+		},
+		{
+			// Free variables must reach the closure in the order they were
+			// captured; swapping them would give -7.
+			input: `
+            let newSub = fn(a, b) { fn() { a - b } };
+            newSub(10, 3)();
+            `,
+			expected: 7,
+		},
+		{
+			input: `
+            let newSub = fn(a, b) { fn() { b - a } };
+            newSub(10, 3)();
+            `,
+			expected: -7,
+		}, {
+			// Sibling closures called from inside each other must read their
+			// own captures again once the inner call has returned.
+			input: `
+            let newGetter = fn(value) {
+                fn(other) {
+                    let before = value;
+                    let inner = other();
+                    [before, inner, value]
+                }
+            };
+            let first = newGetter(1);
+            let second = newGetter(2);
+            [
+                first(fn() { second(fn() { 0 })[0] }),
+                second(fn() { first(fn() { 0 })[0] })
+            ]
+            `,
+			expected: [][]int{{1, 2, 1}, {2, 1, 2}},
+		},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestLetInitializerReadsOuterBinding(tester *testing.T) {
+	tests := []vmTestCase{
+		{`let x = 5; let double = fn() { let x = x * 2; x }; double()`, 10},
+		{`let x = 5; let double = fn() { let x = x * 2; x }; double(); x`, 5},
+		{`let outer = fn(x) { fn() { let x = x + 1; x } }; outer(1)()`, 2},
+	}
+
+	runVmTests(tester, tests)
+}
+
+func TestCompileInto(tester *testing.T) {
+	comp := compiler.New()
+
+	programs := []string{
+		`let add = fn(a, b) { a + b }; let offset = 1000;`,
+		`add(offset, undefined)`,
+		`add(offset, 2)`,
+	}
+
+	for _, input := range programs {
+		comp.CompileInto(parse(input))
+	}
+
+	vm := New(comp.Bytecode())
+	error := vm.Run()
+	if error != nil {
+		tester.Fatalf("vm error: %s", error)
+	}
+
+	testExpectedObject(tester, 1002, vm.LastPoppedStackElem())
+}
+
+func TestCompileIntoAfterFailedGlobals(tester *testing.T) {
+	comp := compiler.New()
+
+	error := comp.CompileInto(parse(`let a = 1; let b = 2; undefined`))
+	if error == nil {
+		tester.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	error = comp.CompileInto(parse(`a + b`))
+	if error == nil {
+		tester.Fatalf("globals of the failed compile are still defined")
+	}
+
+	error = comp.CompileInto(parse(`let c = 3; c * 2`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	vm := New(comp.Bytecode())
+	error = vm.Run()
+	if error != nil {
+		tester.Fatalf("vm error: %s", error)
+	}
+
+	testExpectedObject(tester, 6, vm.LastPoppedStackElem())
+}
+
+func TestImport(tester *testing.T) {
+	dir := tester.TempDir()
+	files := map[string]string{
+		"math.monkey":   `import "helper.monkey"; let double = fn(x) { times(x, 2) };`,
+		"helper.monkey": `let times = fn(a, b) { a * b };`,
+	}
+	for name, source := range files {
+		error := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644)
+		if error != nil {
+			tester.Fatalf("writing %s: %s", name, error)
+		}
+	}
+
+	comp := compiler.New()
+	comp.SetImportDir(dir)
+
+	error := comp.Compile(parse(`import "math.monkey"; import "helper.monkey"; double(21) + times(1, 0)`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	vm := New(comp.Bytecode())
+	error = vm.Run()
+	if error != nil {
+		tester.Fatalf("vm error: %s", error)
+	}
+
+	testExpectedObject(tester, 42, vm.LastPoppedStackElem())
+}
+
+func TestRecursiveFunctions(tester *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+            let countDown = fn(x) {
+                if (x == 0) {
+                    return 0;
+                } else {
+                    countDown(x - 1);
+                }
+            };
+            countDown(1);
+            `,
+			expected: 0,
+		},
+		{
+			input: `
+            let countDown = fn(x) {
+                if (x == 0) {
+                    return 0;
+                } else {
+                    countDown(x - 1);
+                }
+            };
+            let wrapper = fn() {
+                countDown(1);
+            };
+            wrapper();
+            `,
+			expected: 0,
+		},
+		{
+			input: `
+            let wrapper = fn() {
+                let countDown = fn(x) {
+                    if (x == 0) {
+                        return 0;
+                    } else {
+                        countDown(x - 1);
+                    }
+                }
+                countDown(1);
+            }
+            wrapper();
+            `,
+			expected: 0,
 		},
 	}
 
@@ -675,3 +2186,411 @@ func TestRecursiveFibonacci(tester *testing.T) {
 
 	runVmTests(tester, tests)
 }
+
+func runHandBuiltBytecode(constants []object.Object, instructions ...[]byte) error {
+	bytecode := &compiler.Bytecode{Instructions: concatInstructions(instructions...), Constants: constants}
+	return New(bytecode).Run()
+}
+
+func TestStackUnderflow(tester *testing.T) {
+	tests := [][][]byte{
+		{code.Make(code.OpReturnValue)},
+		{code.Make(code.OpConstant, 0), code.Make(code.OpAdd)},
+		{code.Make(code.OpJumpNotTrue, 3)},
+		{code.Make(code.OpMinus)},
+	}
+
+	for _, instructions := range tests {
+		error := runHandBuiltBytecode([]object.Object{&object.Integer{Value: 1}}, instructions...)
+		if error == nil {
+			tester.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if error.Error() != "stack underflow" {
+			tester.Errorf("wrong VM error: want=%q, got=%q", "stack underflow", error)
+		}
+	}
+}
+
+func TestUnknownOpcode(tester *testing.T) {
+	error := runHandBuiltBytecode(nil, []byte{255})
+	if error == nil {
+		tester.Fatalf("expected VM error but resulted in none.")
+	}
+
+	if error.Error() != "unknown opcode 255" {
+		tester.Errorf("wrong VM error: want=%q, got=%q", "unknown opcode 255", error)
+	}
+}
+
+func TestDeepRecursion(tester *testing.T) {
+	runVmErrorTests(tester, []vmTestCase{
+		// Each call keeps its argument and a pending addition on the stack,
+		// which fills up long before MaxFrames.
+		{`let sum = fn(n) { n + sum(n + 1) }; sum(0)`, "stack overflow (deep recursion?)"},
+		// Without arguments or locals the frames run out first.
+		{`let loop = fn() { loop() }; loop()`, "stack overflow (deep recursion?)"},
+	})
+}
+
+func TestExtraPop(tester *testing.T) {
+	constants := []object.Object{&object.Integer{Value: 7}}
+	instructions := concatInstructions(
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpPop),
+		code.Make(code.OpConstant, 0),
+	)
+
+	for _, debug := range []bool{false, true} {
+		vm := New(&compiler.Bytecode{Instructions: instructions, Constants: constants})
+		vm.SetDebug(debug)
+		error := vm.Run()
+
+		if !debug {
+			if error != nil {
+				tester.Fatalf("unexpected VM error: %s", error)
+			}
+
+			if vm.stackPointer != 1 {
+				tester.Errorf("wrong stack pointer after extra pop. want=1, got=%d", vm.stackPointer)
+			}
+
+			testExpectedObject(tester, 7, vm.stack[0])
+			continue
+		}
+
+		if error == nil || error.Error() != "stack underflow" {
+			tester.Errorf("expected stack underflow in debug mode. got=%v", error)
+		}
+	}
+}
+
+func TestReturnValueRejectedOnStack(tester *testing.T) {
+	constants := []object.Object{
+		&object.ReturnValue{Value: &object.Integer{Value: 1}},
+	}
+
+	error := runHandBuiltBytecode(constants, code.Make(code.OpConstant, 0), code.Make(code.OpPop))
+	if error == nil {
+		tester.Fatalf("expected VM error but resulted in none.")
+	}
+
+	expected := "internal error: RETURN_VALUE on the value stack"
+	if error.Error() != expected {
+		tester.Errorf("wrong VM error: want=%q, got=%q", expected, error)
+	}
+}
+
+func TestLocalIndexOutOfRange(tester *testing.T) {
+	tests := []code.Opcode{code.OpGetLocal, code.OpSetLocal}
+
+	for _, op := range tests {
+		fn := &object.CompiledFunction{Instructions: code.Instructions{}}
+		for _, instruction := range [][]byte{
+			code.Make(code.OpSmallInt, 1),
+			code.Make(op, 255),
+			code.Make(code.OpReturnValue),
+		} {
+			fn.Instructions = append(fn.Instructions, instruction...)
+		}
+
+		// Fill most of the stack so the callee's base pointer sits close
+		// enough to StackSize for local 255 to fall outside of it.
+		instructions := [][]byte{}
+		for i := 0; i < StackSize-100; i++ {
+			instructions = append(instructions, code.Make(code.OpSmallInt, 0))
+		}
+		instructions = append(instructions,
+			code.Make(code.OpClosure, 0, 0),
+			code.Make(code.OpCall, 0),
+		)
+
+		error := runHandBuiltBytecode([]object.Object{fn}, instructions...)
+		if error == nil {
+			tester.Fatalf("expected VM error for opcode %d but resulted in none.", op)
+		}
+
+		expected := "local index 255 out of range"
+		if error.Error() != expected {
+			tester.Errorf("wrong VM error: want=%q, got=%q", expected, error)
+		}
+	}
+}
+
+func TestUnknownBuiltinIndex(tester *testing.T) {
+	error := runHandBuiltBytecode(nil, code.Make(code.OpGetBuiltin, 255), code.Make(code.OpPop))
+	if error == nil {
+		tester.Fatalf("expected VM error but resulted in none.")
+	}
+
+	if error.Error() != "unknown builtin index 255" {
+		tester.Errorf("wrong VM error: want=%q, got=%q", "unknown builtin index 255", error)
+	}
+
+	error = runHandBuiltBytecode(nil, code.Make(code.OpGetBuiltin, len(object.Builtins)-1), code.Make(code.OpPop))
+	if error != nil {
+		tester.Errorf("last builtin index rejected: %s", error)
+	}
+}
+
+func TestTrace(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + 2", []string{"0000 OpSmallInt 1", "OpSmallInt 2", "OpAdd", "OpPop"}},
+		{"1000 + 2000", []string{"0000 OpConstant 0", "OpConstant 1", "OpAdd", "OpPop"}},
+	}
+
+	for _, testcase := range tests {
+		comp := compiler.New()
+		if error := comp.Compile(parse(testcase.input)); error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		var log strings.Builder
+		vm := New(comp.Bytecode())
+		vm.SetTrace(&log)
+
+		if error := vm.Run(); error != nil {
+			tester.Fatalf("vm error: %s", error)
+		}
+
+		rest := log.String()
+		for _, expected := range testcase.expected {
+			index := strings.Index(rest, expected)
+			if index < 0 {
+				tester.Fatalf("%q: trace is missing %q in order. got=\n%s", testcase.input, expected, log.String())
+			}
+			rest = rest[index+len(expected):]
+		}
+	}
+
+	comp := compiler.New()
+	comp.Compile(parse("1 + 2"))
+
+	var log strings.Builder
+	vm := New(comp.Bytecode())
+	vm.SetTrace(&log)
+	vm.Run()
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if !strings.HasSuffix(lines[len(lines)-1], " 3") {
+		tester.Errorf("OpPop line does not show the stack top. got=%q", lines[len(lines)-1])
+	}
+}
+
+func TestBreakpointHandler(tester *testing.T) {
+	input := `
+    let double = fn(a) { let b = a * 2; debugger; b };
+    1000;
+    double(3);
+    `
+
+	comp := compiler.New()
+	error := comp.Compile(parse(input))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	breakpoints := []Breakpoint{}
+
+	vm := New(comp.Bytecode())
+	vm.SetBreakpointHandler(func(breakpoint Breakpoint) {
+		breakpoints = append(breakpoints, breakpoint)
+	})
+
+	error = vm.Run()
+	if error != nil {
+		tester.Fatalf("vm error: %s", error)
+	}
+
+	testExpectedObject(tester, 6, vm.LastPoppedStackElem())
+
+	if len(breakpoints) != 1 {
+		tester.Fatalf("wrong number of breakpoints. want=1, got=%d", len(breakpoints))
+	}
+
+	breakpoint := breakpoints[0]
+	if breakpoint.Depth != 2 {
+		tester.Errorf("wrong depth. want=2, got=%d", breakpoint.Depth)
+	}
+
+	if len(breakpoint.Stack) != 3 {
+		tester.Fatalf("wrong stack size. want=3, got=%d", len(breakpoint.Stack))
+	}
+
+	if _, ok := breakpoint.Stack[0].(*object.Closure); !ok {
+		tester.Errorf("stack[0] is not the called closure. got=%T", breakpoint.Stack[0])
+	}
+	testExpectedObject(tester, []int{3, 6}, &object.Array{Elements: breakpoint.Stack[1:]})
+	testExpectedObject(tester, []int{3, 6}, &object.Array{Elements: breakpoint.Locals})
+}
+
+func TestBreakpointWithoutHandler(tester *testing.T) {
+	runVmTests(tester, []vmTestCase{
+		{`debugger; 1`, 1},
+		{`if (true) { debugger; }`, object.NULL},
+	})
+}
+
+func TestVerify(tester *testing.T) {
+	function := &object.CompiledFunction{Instructions: concatInstructions(
+		code.Make(code.OpJump, 40),
+		code.Make(code.OpReturn),
+	)}
+
+	tests := []struct {
+		constants    []object.Object
+		instructions [][]byte
+		expected     string
+	}{
+		{
+			nil,
+			[][]byte{code.Make(code.OpJump, 100)},
+			"main program: invalid jump target 100 at 0000",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpTrue), code.Make(code.OpJumpNotTrue, 2), code.Make(code.OpNull)},
+			"main program: invalid jump target 2 at 0001",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpSetupCatch, 9)},
+			"main program: invalid jump target 9 at 0000",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpConstant, 0), code.Make(code.OpLoop, 1)},
+			"main program: invalid jump target 1 at 0003",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpLoop, 3), code.Make(code.OpNull)},
+			"main program: forward loop target 3 at 0000",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpNull), {255}},
+			"main program: opcode 255 undefined at 0001",
+		},
+		{
+			nil,
+			[][]byte{code.Make(code.OpNull), code.Make(code.OpConstant, 1)[:2]},
+			"main program: truncated OpConstant at 0001",
+		},
+		{
+			[]object.Object{&object.Integer{Value: 1}, function},
+			[][]byte{code.Make(code.OpClosure, 1, 0), code.Make(code.OpPop)},
+			"function constant 1: invalid jump target 40 at 0000",
+		},
+	}
+
+	for _, testcase := range tests {
+		bytecode := &compiler.Bytecode{
+			Instructions: concatInstructions(testcase.instructions...),
+			Constants:    testcase.constants,
+		}
+
+		error := New(bytecode).Verify()
+		if error == nil {
+			tester.Fatalf("expected Verify error %q but resulted in none.", testcase.expected)
+		}
+
+		if error.Error() != testcase.expected {
+			tester.Errorf("wrong Verify error: want=%q, got=%q", testcase.expected, error)
+		}
+	}
+}
+
+func TestLoopDisassembly(tester *testing.T) {
+	comp := compiler.New()
+	error := comp.Compile(parse(`let i = 0; while (i < 3) { let i = i + 1; }`))
+	if error != nil {
+		tester.Fatalf("compiler error: %s", error)
+	}
+
+	disassembly := comp.Bytecode().Instructions.String()
+	if !strings.Contains(disassembly, "0023 OpLoop 5\n") {
+		tester.Errorf("back-edge is not an OpLoop to the condition. got=\n%s", disassembly)
+	}
+
+	if strings.Contains(disassembly, "OpJump ") {
+		tester.Errorf("loop still contains an OpJump. got=\n%s", disassembly)
+	}
+
+	runVmTests(tester, []vmTestCase{
+		{`let i = 0; let total = 0; while (i < 3) { let total = total + i; let i = i + 1; }; total`, 3},
+	})
+}
+
+func TestVerifyAcceptsCompiledPrograms(tester *testing.T) {
+	inputs := []string{
+		`if (true) { 1 } else { 2 }`,
+		`let f = fn(x) { if (x > 1) { x } }; f(2)`,
+		`let i = 0; while (i < 3) { let i = i + 1; }`,
+		`foreach (x in [1, 2]) { x }`,
+		`try { 1 / 0 } catch (e) { e }`,
+	}
+
+	for _, input := range inputs {
+		comp := compiler.New()
+		error := comp.Compile(parse(input))
+		if error != nil {
+			tester.Fatalf("compiler error: %s", error)
+		}
+
+		error = New(comp.Bytecode()).Verify()
+		if error != nil {
+			tester.Errorf("Verify rejected %q: %s", input, error)
+		}
+	}
+}
+
+func concatInstructions(instructions ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, instruction := range instructions {
+		out = append(out, instruction...)
+	}
+
+	return out
+}
+
+func BenchmarkDispatch(benchmark *testing.B) {
+	input := `
+let fibonacci = fn(x) {
+    if (x == 0) {
+        0
+    } else {
+        if (x == 1) {
+            return 1;
+        } else {
+            fibonacci(x - 1) + fibonacci(x - 2);
+        }
+    }
+};
+fibonacci(30);
+`
+
+	comp := compiler.New()
+	error := comp.Compile(parse(input))
+	if error != nil {
+		benchmark.Fatalf("compiler error: %s", error)
+	}
+
+	for range benchmark.N {
+		vm := New(comp.Bytecode())
+
+		error := vm.dispatch(0)
+		if error != nil {
+			benchmark.Fatalf("vm error: %s", error)
+		}
+
+		error = testIntegerObject(832040, vm.LastPoppedStackElem())
+		if error != nil {
+			benchmark.Fatalf("wrong result: %s", error)
+		}
+	}
+}
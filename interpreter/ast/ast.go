@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"sort"
 	"strings"
 )
 
@@ -118,6 +119,15 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -312,6 +322,8 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Keys lists the keys of Pairs in source order.
+	Keys []Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -330,3 +342,21 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// OrderedKeys returns the keys of the literal in source order. Literals built
+// without Keys fall back to ordering the keys by their string form.
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := []Expression{}
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
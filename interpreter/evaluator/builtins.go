@@ -37,7 +37,7 @@ var builtins = map[string]*object.Builtin{
 				return array.Elements[0]
 			}
 
-			return NULL
+			return object.NULL
 		},
 	},
 	"last": {
@@ -56,7 +56,7 @@ var builtins = map[string]*object.Builtin{
 				return array.Elements[length-1]
 			}
 
-			return NULL
+			return object.NULL
 		},
 	},
 	"rest": {
@@ -77,7 +77,7 @@ var builtins = map[string]*object.Builtin{
 				return &object.Array{Elements: newElements}
 			}
 
-			return NULL
+			return object.NULL
 		},
 	},
 	"push": {
@@ -106,7 +106,20 @@ var builtins = map[string]*object.Builtin{
 				fmt.Println(argument.Inspect())
 			}
 
-			return NULL
+			return object.NULL
+		},
+	},
+	"str": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+
+			return &object.String{Value: args[0].Inspect()}
 		},
 	},
 }
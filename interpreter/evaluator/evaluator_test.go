@@ -35,6 +35,41 @@ func TestEvalIntegerExpression(tester *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.0 / 2", 0.5},
+		{"1 / 2.0", 0.5},
+		{"1 + 0.5", 1.5},
+		{"2.5 * 2", 5},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			tester.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if result.Value != testcase.expected {
+			tester.Errorf("object has wrong value. got=%g, want=%g", result.Value, testcase.expected)
+		}
+	}
+
+	testIntegerObject(tester, testEval("1 / 2"), 0)
+	testBooleanObject(tester, testEval("2.0 == 2"), true)
+	testBooleanObject(tester, testEval("1 < 1.5"), true)
+
+	if inspected := testEval("4.0").Inspect(); inspected != "4.0" {
+		tester.Errorf("wrong Inspect. want=%q, got=%q", "4.0", inspected)
+	}
+}
+
 func TestEvalBooleanExpression(tester *testing.T) {
 	tests := []struct {
 		input    string
@@ -191,6 +226,30 @@ func TestErrorHandling(tester *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{"a": 1, fn(x) { x }: 2}`,
+			"unusable as hash key: FUNCTION at pair 2",
+		},
+		{
+			`"count: " + 1`,
+			"cannot concatenate STRING and INTEGER (use str())",
+		},
+		{
+			`1 + "x"`,
+			"cannot concatenate INTEGER and STRING (use str())",
+		},
+		{
+			"5 / 0",
+			"division by zero",
+		},
+		{
+			"5 / (1 - 1)",
+			"division by zero",
+		},
+		{
+			"5.0 / 0",
+			"division by zero",
+		},
 	}
 
 	for _, testcase := range tests {
@@ -268,6 +327,44 @@ func TestFunctionCalling(tester *testing.T) {
 	}
 }
 
+func TestClosures(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+		let newCounter = fn(start) {
+			fn(step) { start + step };
+		};
+		let counter = newCounter(10);
+		counter(1) + counter(2);
+		`, 23},
+		{`
+		let newAdder = fn(x) { fn(y) { x + y } };
+		let addTwo = newAdder(2);
+		let addTen = newAdder(10);
+		addTwo(1) * addTen(1);
+		`, 33},
+		{`
+		let x = 1;
+		let readX = fn() { x };
+		let shadow = fn(x) { readX() };
+		shadow(100);
+		`, 1},
+		{`
+		let outer = fn() {
+			let inner = fn() { return 5; };
+			inner() + 1;
+		};
+		outer();
+		`, 6},
+	}
+
+	for _, testcase := range tests {
+		testIntegerObject(tester, testEval(testcase.input), testcase.expected)
+	}
+}
+
 func TestStringLiteral(tester *testing.T) {
 	input := `"Hello World!"`
 
@@ -282,6 +379,30 @@ func TestStringLiteral(tester *testing.T) {
 	}
 }
 
+func TestStrBuiltin(tester *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"count: " + str(1)`, "count: 1"},
+		{`str("x")`, "x"},
+		{`str([1, true])`, "[1, true]"},
+	}
+
+	for _, testcase := range tests {
+		evaluated := testEval(testcase.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			tester.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != testcase.expected {
+			tester.Errorf("String has wrong value. want=%q, got=%q", testcase.expected, str.Value)
+		}
+	}
+}
+
 func TestStringConcatenation(tester *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -533,8 +654,8 @@ func testBooleanObject(tester *testing.T, obj object.Object, expected bool) bool
 }
 
 func testNullObject(tester *testing.T, obj object.Object) bool {
-	if obj != NULL {
-		tester.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+	if obj != object.NULL {
+		tester.Errorf("object is not object.NULL. got=%T (%+v)", obj, obj)
 		return false
 	}
 
@@ -93,8 +93,7 @@ func (lexer *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(lexer.ch) {
-			tok.Literal = lexer.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = lexer.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lexer.ch)
@@ -114,13 +113,22 @@ func (lexer *Lexer) readIdentifier() string {
 	return lexer.input[position:lexer.position]
 }
 
-func (lexer *Lexer) readNumber() string {
+func (lexer *Lexer) readNumber() (string, token.TokenType) {
 	position := lexer.position
 	for isDigit(lexer.ch) {
 		lexer.readChar()
 	}
 
-	return lexer.input[position:lexer.position]
+	if lexer.ch != '.' || !isDigit(lexer.peekChar()) {
+		return lexer.input[position:lexer.position], token.INT
+	}
+
+	lexer.readChar()
+	for isDigit(lexer.ch) {
+		lexer.readChar()
+	}
+
+	return lexer.input[position:lexer.position], token.FLOAT
 }
 
 func (lexer *Lexer) readString() string {
@@ -16,12 +16,16 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// Get looks name up in env and then in each enclosing environment in turn. The
+// chain is walked iteratively since deep recursion in Monkey code builds long ones.
 func (env *Environment) Get(name string) (Object, bool) {
-	object, ok := env.store[name]
-    if !ok && env.outer != nil {
-        object, ok = env.outer.Get(name)
-    }
-	return object, ok
+	for current := env; current != nil; current = current.outer {
+		if object, ok := current.store[name]; ok {
+			return object, true
+		}
+	}
+
+	return nil, false
 }
 
 func (env *Environment) Set(name string, value Object) Object {
@@ -0,0 +1,48 @@
+package object
+
+import "testing"
+
+func TestEnvironmentGetDeepChain(tester *testing.T) {
+	depth := 100000
+
+	global := NewEnvironment()
+	global.Set("answer", &Integer{Value: 42})
+
+	env := global
+	for i := 0; i < depth; i++ {
+		env = NewEnclosedEnvironment(env)
+	}
+	env.Set("local", &Integer{Value: 7})
+
+	tests := []struct {
+		name     string
+		expected int64
+	}{
+		{"answer", 42},
+		{"local", 7},
+	}
+
+	for _, testcase := range tests {
+		value, ok := env.Get(testcase.name)
+		if !ok {
+			tester.Fatalf("%q not found in environment chain", testcase.name)
+		}
+
+		integer, ok := value.(*Integer)
+		if !ok {
+			tester.Fatalf("%q is not Integer. got=%T (%+v)", testcase.name, value, value)
+		}
+
+		if integer.Value != testcase.expected {
+			tester.Errorf("%q has wrong value. got=%d, want=%d", testcase.name, integer.Value, testcase.expected)
+		}
+	}
+
+	if _, ok := env.Get("missing"); ok {
+		tester.Errorf("undefined name resolved in environment chain")
+	}
+
+	if _, ok := global.Get("local"); ok {
+		tester.Errorf("outer environment resolved a name from an inner one")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +13,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJECT      = "INTEGER"
+	FLOAT_OBJECT        = "FLOAT"
 	BOOLEAN_OBJECT      = "BOOLEAN"
 	NULL_OBJECT         = "NULL"
 	RETURN_VALUE_OBJECT = "RETURN_VALUE"
@@ -26,6 +28,9 @@ const (
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+	// Truthy reports how the object behaves as a condition: a boolean by its
+	// value, null as false and anything else, including 0 and "", as true.
+	Truthy() bool
 }
 
 type Hashable interface {
@@ -37,18 +42,42 @@ type Integer struct {
 }
 
 func (integer *Integer) Type() ObjectType { return INTEGER_OBJECT }
+func (integer *Integer) Truthy() bool     { return true }
 func (integer *Integer) Inspect() string  { return fmt.Sprintf("%d", integer.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (float *Float) Type() ObjectType { return FLOAT_OBJECT }
+func (float *Float) Truthy() bool     { return true }
+
+// Inspect keeps a ".0" on whole numbers so they are not mistaken for integers.
+func (float *Float) Inspect() string {
+	text := strconv.FormatFloat(float.Value, 'g', -1, 64)
+	if strings.ContainsAny(text, ".eIN") {
+		return text
+	}
+
+	return text + ".0"
+}
+
 type Boolean struct {
 	Value bool
 }
 
 func (boolean *Boolean) Type() ObjectType { return BOOLEAN_OBJECT }
+func (boolean *Boolean) Truthy() bool     { return boolean.Value }
 func (boolean *Boolean) Inspect() string  { return fmt.Sprintf("%t", boolean.Value) }
 
 type Null struct{}
 
+// NULL is the only Null value the evaluator produces, so null can be compared
+// by identity.
+var NULL = &Null{}
+
 func (null *Null) Type() ObjectType { return NULL_OBJECT }
+func (null *Null) Truthy() bool     { return false }
 func (null *Null) Inspect() string  { return "null" }
 
 type ReturnValue struct {
@@ -56,6 +85,7 @@ type ReturnValue struct {
 }
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJECT }
+func (rv *ReturnValue) Truthy() bool     { return rv.Value.Truthy() }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
@@ -63,6 +93,7 @@ type Error struct {
 }
 
 func (err *Error) Type() ObjectType { return ERROR_OBJECT }
+func (err *Error) Truthy() bool     { return true }
 func (err *Error) Inspect() string  { return "ERROR: " + err.Message }
 
 type Function struct {
@@ -72,6 +103,7 @@ type Function struct {
 }
 
 func (fn *Function) Type() ObjectType { return FUNCTION_OBJECT }
+func (fn *Function) Truthy() bool     { return true }
 func (fn *Function) Inspect() string {
 	var out bytes.Buffer
 
@@ -95,6 +127,7 @@ type String struct {
 }
 
 func (str *String) Type() ObjectType { return STRING_OBJECT }
+func (str *String) Truthy() bool     { return true }
 func (str *String) Inspect() string  { return str.Value }
 
 type BuiltinFunction func(args ...Object) Object
@@ -104,6 +137,7 @@ type Builtin struct {
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJECT }
+func (b *Builtin) Truthy() bool     { return true }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
 type Array struct {
@@ -111,6 +145,7 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJECT }
+func (a *Array) Truthy() bool     { return true }
 func (a *Array) Inspect() string {
 	var out bytes.Buffer
 
@@ -164,6 +199,7 @@ type Hash struct {
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJECT }
+func (h *Hash) Truthy() bool     { return true }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
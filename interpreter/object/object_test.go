@@ -0,0 +1,29 @@
+package object
+
+import "testing"
+
+func TestTruthy(tester *testing.T) {
+	tests := []struct {
+		object   Object
+		expected bool
+	}{
+		{&Boolean{Value: true}, true},
+		{&Boolean{Value: false}, false},
+		{&Null{}, false},
+		{&Integer{Value: 0}, true},
+		{&Float{Value: 0}, true},
+		{&String{Value: ""}, true},
+		{&Array{}, true},
+		{&Hash{Pairs: map[HashKey]HashPair{}}, true},
+		{&Function{}, true},
+		{&Builtin{}, true},
+		{&Error{Message: "boom"}, true},
+		{&ReturnValue{Value: &Boolean{Value: false}}, false},
+	}
+
+	for _, testcase := range tests {
+		if truthy := testcase.object.Truthy(); truthy != testcase.expected {
+			tester.Errorf("%T: Truthy() wrong. want=%t, got=%t", testcase.object, testcase.expected, truthy)
+		}
+	}
+}
@@ -28,6 +28,7 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.prefixParseFunctions = make(map[token.TokenType]prefixParseFunction)
 	parser.registerPrefix(token.IDENT, parser.parseIdentifier)
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
@@ -191,6 +192,20 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (parser *Parser) parseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: parser.currentToken}
+
+	value, err := strconv.ParseFloat(parser.currentToken.Literal, 64)
+	if err != nil {
+		message := fmt.Sprintf("could not parse %q as float", parser.currentToken.Literal)
+		parser.errors = append(parser.errors, message)
+		return nil
+	}
+
+	literal.Value = value
+	return literal
+}
+
 func (parser *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
 }
@@ -404,6 +419,7 @@ func (parser *Parser) parseHashLiteral() ast.Expression {
 		value := parser.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
 			return nil
@@ -137,6 +137,23 @@ func TestIntegerLiteralExpression(tester *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(tester *testing.T) {
+	lexer := lexer.New("3.25;")
+	parser := New(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(tester, parser)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.FloatLiteral)
+	if !ok {
+		tester.Fatalf("expressions is not *ast.FloatLiteral. got=%T", statement.Expression)
+	}
+
+	if literal.Value != 3.25 {
+		tester.Errorf("literal.Value not %g. got=%g", 3.25, literal.Value)
+	}
+}
+
 func TestParsingPrefixExpressions(tester *testing.T) {
 	prefixTests := []struct {
 		input        string